@@ -0,0 +1,255 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/features"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Kinds of Baseline detector
+const (
+	ZScoreKind     = "zscore"
+	MADKind        = "mad"
+	PercentileKind = "percentile"
+)
+
+func init() {
+	for _, kind := range []string{ZScoreKind, MADKind, PercentileKind} {
+		kind := kind
+		Register(kind, func() Detector { return &Baseline{Method: kind} })
+	}
+}
+
+// Baseline is a cheap univariate detector, each column is scored on its own
+// against a baseline of its past values and a row gets the score of its
+// worst column. How far a value is from the baseline depends on Method:
+//
+//	zscore      standard deviations from the mean
+//	mad         robust standard deviations from the median, using the
+//	            median absolute deviation
+//	percentile  half band widths outside the band between the 100-Percentile
+//	            and Percentile percentiles, 0 inside it
+//
+// Distances are put on the forests' scale, fitted to the training data: a
+// row as far out as the typical training row scores 0.4 and one Threshold
+// away scores 0.6, so the same threshold, around 0.6, flags anomalies on
+// either. Percentile distances only start at the edge of the band, so it
+// usually wants a smaller Threshold, around 0.5, than zscore or mad.
+//
+// Spreads are never taken as less than minSpread of the baseline's level, so
+// a flat baseline puts a change a long but finite way out.
+//
+// Feed it the raw dimensions, lagged or smoothed copies of a column only
+// count it again.
+type Baseline struct {
+	Method     string  `json:"method"`
+	Threshold  float64 `json:"threshold"`
+	Percentile float64 `json:"percentile"`
+
+	// Window is how many past values make up the baseline. Scored values
+	// join it as they go, so it rolls forward through the data being scored.
+	// Every Score call starts again from the training data though, as the
+	// scripts score overlapping windows that would otherwise be counted
+	// twice. 0 means use all the training data and keep it fixed.
+	Window int `json:"window"`
+
+	// Past values of each column, the last Window of them if it is set
+	History [][]float64 `json:"history"`
+
+	// Typical is the median distance of a training row from the baseline,
+	// in spreads, what calibrate scores as 0.4
+	Typical float64 `json:"typical"`
+}
+
+// NewBaseline makes an untrained baseline detector, method is one of the
+// Baseline kinds
+func NewBaseline(method string, window int, threshold, percentile float64) *Baseline {
+	return &Baseline{Method: method, Window: window, Threshold: threshold, Percentile: percentile}
+}
+
+// Kind implements Detector
+func (b *Baseline) Kind() string {
+	return b.Method
+}
+
+// Fit implements Detector
+func (b *Baseline) Fit(x *features.Matrix) error {
+	return b.FitDense(x.Dense)
+}
+
+// FitDense keeps the rows of x, or the last Window of them, as the baseline
+func (b *Baseline) FitDense(x *mat.Dense) error {
+	switch b.Method {
+	case ZScoreKind, MADKind, PercentileKind:
+	default:
+		return fmt.Errorf("anomaly: unknown baseline method %q", b.Method)
+	}
+	if b.Threshold <= 0 {
+		return fmt.Errorf("anomaly: baseline threshold must be > 0, got %v", b.Threshold)
+	}
+	if b.Method == PercentileKind && (b.Percentile <= 50 || b.Percentile > 100) {
+		return fmt.Errorf("anomaly: baseline percentile must be > 50 and <= 100, got %v", b.Percentile)
+	}
+	rows, cols := x.Dims()
+	if rows < 2 {
+		return fmt.Errorf("anomaly: baseline needs at least 2 rows, got %d", rows)
+	}
+	b.History = make([][]float64, cols)
+	devs := make([]float64, rows)
+	for j := range b.History {
+		col := mat.Col(nil, j, x)
+		b.History[j] = tail(col, b.Window)
+		dev := b.deviation(b.History[j])
+		for i, v := range col {
+			devs[i] = math.Max(devs[i], dev(v))
+		}
+	}
+	b.Typical = quantile(devs, 0.5)
+	return nil
+}
+
+// Score implements Detector
+func (b *Baseline) Score(x *features.Matrix) ([]float64, error) {
+	cols, err := b.ScoreColumns(x.Dense)
+	if err != nil {
+		return nil, err
+	}
+	rows, _ := x.Dims()
+	scores := make([]float64, rows)
+	for _, col := range cols {
+		for i, s := range col {
+			if s > scores[i] {
+				scores[i] = s
+			}
+		}
+	}
+	return scores, nil
+}
+
+// ScoreColumns scores every value of x, one slice of scores per column
+func (b *Baseline) ScoreColumns(x *mat.Dense) ([][]float64, error) {
+	if b.History == nil {
+		return nil, fmt.Errorf("anomaly: baseline has not been fit")
+	}
+	rows, cols := x.Dims()
+	if cols != len(b.History) {
+		return nil, fmt.Errorf("anomaly: baseline was fit on %d columns, got %d", len(b.History), cols)
+	}
+	scores := make([][]float64, cols)
+	for j := range scores {
+		scores[j] = make([]float64, rows)
+		history := clone(b.History[j])
+		var dev func(v float64) float64
+		if b.Window == 0 {
+			dev = b.deviation(history)
+		}
+		for i := range scores[j] {
+			v := x.At(i, j)
+			if b.Window > 0 {
+				dev = b.deviation(tail(history, b.Window))
+				history = append(history, v)
+			}
+			scores[j][i] = calibrate(dev(v), b.Typical, b.Threshold)
+		}
+	}
+	return scores, nil
+}
+
+// How far values are from a baseline of past values
+func (b *Baseline) deviation(past []float64) func(v float64) float64 {
+	switch b.Method {
+	case ZScoreKind:
+		var mean, sd float64
+		for _, v := range past {
+			mean += v
+		}
+		mean /= float64(len(past))
+		for _, v := range past {
+			sd += (v - mean) * (v - mean)
+		}
+		sd = math.Sqrt(sd / float64(len(past)))
+		return func(v float64) float64 { return spreads(v-mean, sd, mean) }
+
+	case MADKind:
+		med := quantile(past, 0.5)
+		mad := robustSpread(past, med)
+		return func(v float64) float64 { return spreads(v-med, mad, med) }
+
+	default:
+		lo := quantile(past, 1-b.Percentile/100)
+		hi := quantile(past, b.Percentile/100)
+		half := (hi - lo) / 2
+		mid := (hi + lo) / 2
+		return func(v float64) float64 {
+			switch {
+			case v < lo:
+				return spreads(lo-v, half, mid)
+			case v > hi:
+				return spreads(v-hi, half, mid)
+			}
+			return 0
+		}
+	}
+}
+
+// Turn a distance d into a score on the forests' scale, a logistic curve in
+// log d through 0.4 at typical and 0.6 at threshold. Typical is kept between
+// a tenth and a half of threshold so the curve is never too flat or steep.
+func calibrate(d, typical, threshold float64) float64 {
+	if d <= 0 {
+		return 0
+	}
+	typical = math.Max(threshold/10, math.Min(threshold/2, typical))
+	mid := (math.Log(typical) + math.Log(threshold)) / 2
+	width := (math.Log(threshold) - math.Log(typical)) / (2 * math.Log(1.5))
+	return 1 / (1 + math.Exp(-(math.Log(d)-mid)/width))
+}
+
+// minSpread is the smallest spread used, as a fraction of the level of the
+// values and never less than that in absolute terms
+const minSpread = 0.01
+
+// How many spreads d is from values around level
+func spreads(d, spread, level float64) float64 {
+	return math.Abs(d) / math.Max(spread, minSpread*math.Max(1, math.Abs(level)))
+}
+
+// A robust standard deviation of x around center, the median absolute
+// deviation scaled to match the standard deviation for normal data. That is
+// 0 once more than half of x is the same, as with a mostly idle counter, so
+// then the mean absolute deviation is used, scaled the same way.
+func robustSpread(x []float64, center float64) float64 {
+	abs := make([]float64, len(x))
+	var mean float64
+	for i, v := range x {
+		abs[i] = math.Abs(v - center)
+		mean += abs[i] / float64(len(x))
+	}
+	if mad := quantile(abs, 0.5); mad > 0 {
+		return 1.4826 * mad
+	}
+	return 1.2533 * mean
+}
+
+// The q quantile of x, interpolating between the closest values
+func quantile(x []float64, q float64) float64 {
+	sorted := clone(x)
+	sort.Float64s(sorted)
+	pos := q * float64(len(sorted)-1)
+	i := int(pos)
+	if i >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// The last n values of x, or all of them if n is 0
+func tail(x []float64, n int) []float64 {
+	if n > 0 && len(x) > n {
+		return x[len(x)-n:]
+	}
+	return x
+}
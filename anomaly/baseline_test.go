@@ -0,0 +1,119 @@
+package anomaly
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andrewm4894/learn-go/features"
+	"gonum.org/v1/gonum/mat"
+)
+
+func TestBaselineSparseCounter(t *testing.T) {
+	// Mostly idle, busy 3 rows in 10, so the median absolute deviation is 0
+	train := make([]float64, 100)
+	for i := range train {
+		if i%10 < 3 {
+			train[i] = 5 + float64(i%3)
+		}
+	}
+	b := NewBaseline(MADKind, 0, 3, 0)
+	if err := b.FitDense(mat.NewDense(len(train), 1, train)); err != nil {
+		t.Fatal(err)
+	}
+	scores, err := b.ScoreColumns(mat.NewDense(3, 1, []float64{0, 6, 100}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idle, busy, spike := scores[0][0], scores[0][1], scores[0][2]
+	if idle != 0 {
+		t.Errorf("idle value scored %v, want 0", idle)
+	}
+	if busy >= 0.6 {
+		t.Errorf("usual busy value scored %v, want under 0.6", busy)
+	}
+	if spike <= 0.7 {
+		t.Errorf("spike scored %v, want over 0.7", spike)
+	}
+}
+
+func TestBaselineFlat(t *testing.T) {
+	train := make([]float64, 50)
+	for i := range train {
+		train[i] = 100
+	}
+	for _, method := range []string{ZScoreKind, MADKind, PercentileKind} {
+		b := NewBaseline(method, 0, 3, 99)
+		if err := b.FitDense(mat.NewDense(len(train), 1, train)); err != nil {
+			t.Fatal(err)
+		}
+		scores, err := b.ScoreColumns(mat.NewDense(3, 1, []float64{100, 101, 103}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		same, small, big := scores[0][0], scores[0][1], scores[0][2]
+		for _, s := range scores[0] {
+			if math.IsNaN(s) || s < 0 || s > 1 {
+				t.Errorf("%s: score %v is not in [0, 1]", method, s)
+			}
+		}
+		if same != 0 || !(small > 0 && small < big) {
+			t.Errorf("%s: flat baseline scored 100, 101 and 103 as %v, %v and %v, want 0 then rising", method, same, small, big)
+		}
+	}
+}
+
+// One threshold flags the gaussian outliers on both the forest and the
+// baselines, without flagging many normal rows
+func TestBaselineForestScale(t *testing.T) {
+	x := gaussianOutliers(t)
+	normal := mat.DenseCopyOf(x.Slice(0, 1000, 0, 2))
+	const threshold = 0.6
+
+	forest := NewIForest(100, 256, 0, 0, rand.New(rand.NewSource(42)))
+	if err := forest.FitDense(normal); err != nil {
+		t.Fatal(err)
+	}
+	forestScores, err := forest.ScoreDense(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := map[string][]float64{"iforest": forestScores}
+	for _, method := range []string{ZScoreKind, MADKind} {
+		b := NewBaseline(method, 0, 3, 0)
+		if err := b.FitDense(normal); err != nil {
+			t.Fatal(err)
+		}
+		m := &features.Matrix{Dense: x}
+		if all[method], err = b.Score(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for kind, scores := range all {
+		var mean float64
+		flagged := 0
+		for _, s := range scores[:1000] {
+			mean += s / 1000
+			if s >= threshold {
+				flagged++
+			}
+		}
+		found := 0
+		for _, s := range scores[1000:] {
+			if s >= threshold {
+				found++
+			}
+		}
+		t.Logf("%s: normal rows average %.3f, %d flagged, %d of 10 outliers found", kind, mean, flagged, found)
+		if mean < 0.3 || mean > 0.5 {
+			t.Errorf("%s: normal rows average %.3f, want 0.3 to 0.5", kind, mean)
+		}
+		if flagged > 60 {
+			t.Errorf("%s: %d of 1000 normal rows flagged at %v, want at most 60", kind, flagged, threshold)
+		}
+		if found < 9 {
+			t.Errorf("%s: %d of 10 outliers flagged at %v, want at least 9", kind, found, threshold)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/features"
+)
+
+// Detector learns what normal looks like from a feature matrix and then
+// scores rows of new data. Scores are from 0 to 1, close to 1 is anomalous.
+// Where normal rows sit depends on the kind:
+//
+//	forests     normal rows around 0.4, clear outliers 0.6 and up
+//	baselines   fitted to the forests' scale, a typical training row
+//	            scores 0.4 and one Threshold spreads out 0.6
+//	seasonal    normal residuals near 0, Threshold spreads out scores 0.5
+//	kmeans      the fraction of training rows nearer their centroid, so
+//	            0.99 is as far out as the furthest 1% of training rows
+//
+// Detectors are saved to disk as json, so anything they need to Score must
+// be marshalled by encoding/json.
+type Detector interface {
+	// Kind names the detector, as used in the config and saved model files
+	Kind() string
+
+	Fit(x *features.Matrix) error
+	Score(x *features.Matrix) ([]float64, error)
+}
+
+// The kinds of detector a saved model can be loaded as
+var kinds = map[string]func() Detector{}
+
+// Register makes a kind of detector loadable by LoadModel, newFn should
+// return an empty detector ready to be unmarshalled into
+func Register(kind string, newFn func() Detector) {
+	if _, ok := kinds[kind]; ok {
+		panic("anomaly: detector kind registered twice: " + kind)
+	}
+	kinds[kind] = newFn
+}
+
+// Kinds lists the registered detector kinds
+func Kinds() []string {
+	var names []string
+	for kind := range kinds {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newDetector(kind string) (Detector, error) {
+	newFn, ok := kinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("anomaly: unknown detector kind %q", kind)
+	}
+	return newFn(), nil
+}
@@ -0,0 +1,79 @@
+package anomaly
+
+import (
+	"fmt"
+
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/sjwhitworth/golearn/base"
+	"github.com/sjwhitworth/golearn/trees"
+	"gonum.org/v1/gonum/mat"
+)
+
+// ForestKind is the Kind of a golearn Forest
+const ForestKind = "golearn-iforest"
+
+func init() {
+	Register(ForestKind, func() Detector { return &Forest{} })
+}
+
+// Forest is a golearn isolation forest. Golearn keeps the trees private so
+// a trained Forest can't be saved, SaveModel gives ErrNotSaveable for it.
+// Use an IForest for models that need to outlive the process.
+type Forest struct {
+	NTrees   int
+	MaxDepth int
+	SubSpace int
+
+	fitted bool
+	forest trees.IsolationForest
+}
+
+// NewForest makes an untrained forest
+func NewForest(nTrees, maxDepth, subSpace int) *Forest {
+	return &Forest{NTrees: nTrees, MaxDepth: maxDepth, SubSpace: subSpace}
+}
+
+// Kind implements Detector
+func (f *Forest) Kind() string {
+	return ForestKind
+}
+
+// Fit implements Detector
+func (f *Forest) Fit(x *features.Matrix) error {
+	f.forest = trees.NewIsolationForest(f.NTrees, f.MaxDepth, f.SubSpace)
+	f.forest.Fit(instances(x.Dense))
+	f.fitted = true
+	return nil
+}
+
+// Score implements Detector
+func (f *Forest) Score(x *features.Matrix) ([]float64, error) {
+	if !f.fitted {
+		return nil, fmt.Errorf("anomaly: forest has not been fit")
+	}
+	return f.forest.Predict(instances(x.Dense)), nil
+}
+
+// Make golearn instances from a matrix
+func instances(x *mat.Dense) base.FixedDataGrid {
+	nRows, nCols := x.Dims()
+	inst := base.InstancesFromMat64(nRows, nCols, x)
+
+	// Must set a class attribute in golearn
+	// Ok to just use any feature as per comment here:
+	// https://github.com/sjwhitworth/golearn/issues/260#issuecomment-756086922
+	attrArray := inst.AllAttributes()
+	inst.AddClassAttribute(attrArray[0])
+
+	return inst
+}
+
+// MarshalJSON always fails, there is no way to get at golearn's trees
+func (f *Forest) MarshalJSON() ([]byte, error) {
+	return nil, fmt.Errorf("%w: golearn keeps its isolation forest's trees private, use %q to save models", ErrNotSaveable, IForestKind)
+}
+
+// UnmarshalJSON always fails, as Forests are never saved
+func (f *Forest) UnmarshalJSON(b []byte) error {
+	return fmt.Errorf("%w: %q models can't be loaded", ErrNotSaveable, ForestKind)
+}
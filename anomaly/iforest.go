@@ -0,0 +1,217 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"github.com/andrewm4894/learn-go/features"
+	"gonum.org/v1/gonum/mat"
+)
+
+// IForestKind is the Kind of an IForest
+const IForestKind = "iforest"
+
+func init() {
+	Register(IForestKind, func() Detector { return &IForest{} })
+}
+
+// IForest is an isolation forest built straight on mat.Dense. All the
+// randomness comes from its own rand.Rand, so the same seed and data always
+// give the same trees and the same scores. Unlike Forest the trees
+// themselves are saved, so loading does not refit.
+type IForest struct {
+	NTrees int `json:"nTrees"`
+
+	// Rows sampled, without replacement, to grow each tree. 0 means 256 or
+	// all the rows if there are fewer.
+	SampleSize int `json:"sampleSize"`
+
+	// Deepest a tree can grow. 0 means log2 of the sample size, past which
+	// points are not anomalous enough to be worth isolating.
+	MaxDepth int `json:"maxDepth"`
+
+	// Columns each tree may split on, picked at random per tree. 0 means all.
+	MaxFeatures int `json:"maxFeatures"`
+
+	// Rows each tree was actually grown on, normalises the path lengths
+	NSamples int     `json:"nSamples"`
+	Cols     int     `json:"cols"`
+	Trees    []iTree `json:"trees"`
+
+	rng *rand.Rand
+}
+
+// An isolation tree as a flat list of nodes, the root is node 0
+type iTree []iNode
+
+// A split on Feature at Split, going Left if less than. Leaves have Left
+// and Right of -1 and Size set to the number of sampled rows that ended up
+// there.
+type iNode struct {
+	Feature int     `json:"f"`
+	Split   float64 `json:"s"`
+	Left    int     `json:"l"`
+	Right   int     `json:"r"`
+	Size    int     `json:"n"`
+}
+
+// NewIForest makes an untrained isolation forest, rng is used to sample
+// rows and features and to pick splits
+func NewIForest(nTrees, sampleSize, maxDepth, maxFeatures int, rng *rand.Rand) *IForest {
+	return &IForest{NTrees: nTrees, SampleSize: sampleSize, MaxDepth: maxDepth, MaxFeatures: maxFeatures, rng: rng}
+}
+
+// Kind implements Detector
+func (f *IForest) Kind() string {
+	return IForestKind
+}
+
+// Fit implements Detector
+func (f *IForest) Fit(x *features.Matrix) error {
+	return f.FitDense(x.Dense)
+}
+
+// FitDense grows the forest on the rows of x
+func (f *IForest) FitDense(x *mat.Dense) error {
+	rows, cols := x.Dims()
+	if rows < 2 {
+		return fmt.Errorf("anomaly: iforest needs at least 2 rows, got %d", rows)
+	}
+	if f.NTrees < 1 {
+		return fmt.Errorf("anomaly: iforest needs at least 1 tree, got %d", f.NTrees)
+	}
+	if f.rng == nil {
+		return fmt.Errorf("anomaly: iforest has no rand.Rand to fit with")
+	}
+
+	n := f.SampleSize
+	if n <= 0 {
+		n = 256
+	}
+	if n > rows {
+		n = rows
+	}
+	depth := f.MaxDepth
+	if depth <= 0 {
+		depth = int(math.Ceil(math.Log2(float64(n))))
+	}
+	nFeatures := f.MaxFeatures
+	if nFeatures <= 0 || nFeatures > cols {
+		nFeatures = cols
+	}
+
+	f.NSamples, f.Cols = n, cols
+	f.Trees = make([]iTree, f.NTrees)
+	for t := range f.Trees {
+		sample := f.rng.Perm(rows)[:n]
+		feats := f.rng.Perm(cols)[:nFeatures]
+		f.Trees[t] = f.grow(x, sample, feats, depth)
+	}
+	return nil
+}
+
+// Grow one tree on the sample rows of x
+func (f *IForest) grow(x *mat.Dense, sample, feats []int, maxDepth int) iTree {
+	var tree iTree
+	var build func(idx []int, depth int) int
+	build = func(idx []int, depth int) int {
+		at := len(tree)
+		tree = append(tree, iNode{Left: -1, Right: -1, Size: len(idx)})
+		if depth >= maxDepth || len(idx) < 2 {
+			return at
+		}
+
+		// Try the features in a random order and split on the first one that
+		// is not constant in this node
+		for _, j := range f.rng.Perm(len(feats)) {
+			feature := feats[j]
+			lo, hi := x.At(idx[0], feature), x.At(idx[0], feature)
+			for _, i := range idx[1:] {
+				v := x.At(i, feature)
+				if v < lo {
+					lo = v
+				}
+				if v > hi {
+					hi = v
+				}
+			}
+			if lo == hi {
+				continue
+			}
+			split := lo + f.rng.Float64()*(hi-lo)
+
+			var left, right []int
+			for _, i := range idx {
+				if x.At(i, feature) < split {
+					left = append(left, i)
+				} else {
+					right = append(right, i)
+				}
+			}
+			l := build(left, depth+1)
+			r := build(right, depth+1)
+			tree[at] = iNode{Feature: feature, Split: split, Left: l, Right: r}
+			return at
+		}
+		return at
+	}
+	build(sample, 0)
+	return tree
+}
+
+// Score implements Detector
+func (f *IForest) Score(x *features.Matrix) ([]float64, error) {
+	return f.ScoreDense(x.Dense)
+}
+
+// ScoreDense scores each row of x from 0 to 1, anything much over 0.5 is
+// easier to isolate than normal
+func (f *IForest) ScoreDense(x *mat.Dense) ([]float64, error) {
+	if len(f.Trees) == 0 {
+		return nil, fmt.Errorf("anomaly: iforest has not been fit")
+	}
+	rows, cols := x.Dims()
+	if cols != f.Cols {
+		return nil, fmt.Errorf("anomaly: iforest was fit on %d columns, got %d", f.Cols, cols)
+	}
+	norm := avgPathLength(f.NSamples)
+	scores := make([]float64, rows)
+	for i := range scores {
+		var total float64
+		for _, tree := range f.Trees {
+			total += tree.pathLength(x, i)
+		}
+		scores[i] = math.Pow(2, -total/float64(len(f.Trees))/norm)
+	}
+	return scores, nil
+}
+
+// Path length to isolate row i of x, leaves that still hold more than one
+// row add on the expected length of the tree they would have grown into
+func (t iTree) pathLength(x *mat.Dense, i int) float64 {
+	var depth float64
+	n := t[0]
+	for n.Left >= 0 {
+		if x.At(i, n.Feature) < n.Split {
+			n = t[n.Left]
+		} else {
+			n = t[n.Right]
+		}
+		depth++
+	}
+	return depth + avgPathLength(n.Size)
+}
+
+// Average path length of an unsuccessful search in a binary search tree of
+// n points, c(n) in the isolation forest paper
+func avgPathLength(n int) float64 {
+	switch {
+	case n <= 1:
+		return 0
+	case n == 2:
+		return 1
+	}
+	m := float64(n - 1)
+	return 2*(math.Log(m)+0.5772156649015329) - 2*m/float64(n)
+}
@@ -0,0 +1,106 @@
+package anomaly
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"testing"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// The 1000 normal rows and 10 outliers that isolationForest.go uses
+func gaussianOutliers(t *testing.T) *mat.Dense {
+	t.Helper()
+	f, err := os.Open("../data/gaussian_outliers.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	records = records[1:]
+	x := mat.NewDense(len(records), len(records[0]), nil)
+	for i, record := range records {
+		for j, field := range record {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			x.Set(i, j, v)
+		}
+	}
+	return x
+}
+
+func fitIForest(t *testing.T, x *mat.Dense, seed int64) (*IForest, []float64) {
+	t.Helper()
+	f := NewIForest(100, 850, 100, 0, rand.New(rand.NewSource(seed)))
+	if err := f.FitDense(x); err != nil {
+		t.Fatal(err)
+	}
+	scores, err := f.ScoreDense(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f, scores
+}
+
+func TestIForestReproducible(t *testing.T) {
+	x := gaussianOutliers(t)
+	f, first := fitIForest(t, x, 42)
+	_, second := fitIForest(t, x, 42)
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("row %d scored %v then %v with the same seed", i, first[i], second[i])
+		}
+	}
+
+	// A saved forest scores the same as the one that was trained
+	b, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded IForest
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := loaded.ScoreDense(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range first {
+		if first[i] != reloaded[i] {
+			t.Fatalf("row %d scored %v before saving and %v after", i, first[i], reloaded[i])
+		}
+	}
+}
+
+func TestIForestFindsOutliers(t *testing.T) {
+	x := gaussianOutliers(t)
+	for _, seed := range []int64{1, 2, 42} {
+		_, scores := fitIForest(t, x, seed)
+
+		// Every outlier is above nearly all normal rows, and on average
+		// above all of them. Some outliers are only just outside the normal
+		// cloud so can't be expected to beat its very edge.
+		normal := append([]float64(nil), scores[:1000]...)
+		sort.Float64s(normal)
+		p99, max := normal[989], normal[999]
+		var mean float64
+		for i := 1000; i < 1010; i++ {
+			mean += scores[i] / 10
+			if scores[i] <= p99 {
+				t.Errorf("seed %d: outlier row %d scored %.3f, not above 99%% of normal rows (%.3f)", seed, i, scores[i], p99)
+			}
+		}
+		if mean <= max {
+			t.Errorf("seed %d: outliers average %.3f, not above the highest normal row's %.3f", seed, mean, max)
+		}
+	}
+}
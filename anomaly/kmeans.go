@@ -0,0 +1,201 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/features"
+	"gonum.org/v1/gonum/mat"
+)
+
+// KMeansKind is the Kind of a KMeans
+const KMeansKind = "kmeans"
+
+func init() {
+	Register(KMeansKind, func() Detector { return &KMeans{} })
+}
+
+// KMeans clusters the training rows and scores new rows by how far they are
+// from the nearest centroid, the same idea as Netdata's own anomaly
+// detection. Distances are turned into scores by where they fall among the
+// training distances, so 0.99 is further out than 99% of the training rows.
+type KMeans struct {
+	K       int `json:"k"`
+	MaxIter int `json:"maxIterations"`
+
+	Centroids [][]float64 `json:"centroids"`
+
+	// Distance from each training row to its nearest centroid, sorted
+	TrainDist []float64 `json:"trainDist"`
+
+	rng *rand.Rand
+}
+
+// NewKMeans makes an untrained k-means detector, rng picks the starting
+// centroids
+func NewKMeans(k, maxIter int, rng *rand.Rand) *KMeans {
+	return &KMeans{K: k, MaxIter: maxIter, rng: rng}
+}
+
+// Kind implements Detector
+func (k *KMeans) Kind() string {
+	return KMeansKind
+}
+
+// Fit implements Detector
+func (k *KMeans) Fit(x *features.Matrix) error {
+	return k.FitDense(x.Dense)
+}
+
+// FitDense clusters the rows of x with k-means++ starting centroids
+func (k *KMeans) FitDense(x *mat.Dense) error {
+	rows, _ := x.Dims()
+	if k.K < 1 {
+		return fmt.Errorf("anomaly: kmeans needs k of at least 1, got %d", k.K)
+	}
+	if rows < k.K {
+		return fmt.Errorf("anomaly: kmeans needs at least k=%d rows, got %d", k.K, rows)
+	}
+	if k.rng == nil {
+		return fmt.Errorf("anomaly: kmeans has no rand.Rand to fit with")
+	}
+
+	data := make([][]float64, rows)
+	for i := range data {
+		data[i] = mat.Row(nil, i, x)
+	}
+	k.Centroids = k.initCentroids(data)
+
+	// Lloyd's algorithm, stopping early once no row changes cluster
+	assign := make([]int, rows)
+	for i := range assign {
+		assign[i] = -1
+	}
+	for iter := 0; iter < k.MaxIter; iter++ {
+		changed := false
+		for i, row := range data {
+			c, _ := k.nearest(row)
+			if c != assign[i] {
+				assign[i] = c
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+		k.update(data, assign)
+	}
+
+	k.TrainDist = make([]float64, rows)
+	for i, row := range data {
+		_, k.TrainDist[i] = k.nearest(row)
+	}
+	sort.Float64s(k.TrainDist)
+	return nil
+}
+
+// Pick starting centroids with k-means++, each one chosen with probability
+// proportional to its squared distance from the closest one already picked
+func (k *KMeans) initCentroids(data [][]float64) [][]float64 {
+	centroids := [][]float64{clone(data[k.rng.Intn(len(data))])}
+	d2 := make([]float64, len(data))
+	for len(centroids) < k.K {
+		var total float64
+		for i, row := range data {
+			d2[i] = math.Inf(1)
+			for _, c := range centroids {
+				if d := sqDist(row, c); d < d2[i] {
+					d2[i] = d
+				}
+			}
+			total += d2[i]
+		}
+
+		// Every row sits on a centroid already, any row will do
+		next := k.rng.Intn(len(data))
+		if total > 0 {
+			target := k.rng.Float64() * total
+			for i, d := range d2 {
+				target -= d
+				if target < 0 {
+					next = i
+					break
+				}
+			}
+		}
+		centroids = append(centroids, clone(data[next]))
+	}
+	return centroids
+}
+
+// Move each centroid to the mean of its rows, empty clusters stay put
+func (k *KMeans) update(data [][]float64, assign []int) {
+	counts := make([]int, len(k.Centroids))
+	sums := make([][]float64, len(k.Centroids))
+	for c := range sums {
+		sums[c] = make([]float64, len(k.Centroids[c]))
+	}
+	for i, row := range data {
+		c := assign[i]
+		counts[c]++
+		for j, v := range row {
+			sums[c][j] += v
+		}
+	}
+	for c, sum := range sums {
+		if counts[c] == 0 {
+			continue
+		}
+		for j := range sum {
+			k.Centroids[c][j] = sum[j] / float64(counts[c])
+		}
+	}
+}
+
+// Index of and distance to the closest centroid
+func (k *KMeans) nearest(row []float64) (int, float64) {
+	best, bestD := 0, math.Inf(1)
+	for c, centroid := range k.Centroids {
+		if d := sqDist(row, centroid); d < bestD {
+			best, bestD = c, d
+		}
+	}
+	return best, math.Sqrt(bestD)
+}
+
+// Score implements Detector
+func (k *KMeans) Score(x *features.Matrix) ([]float64, error) {
+	return k.ScoreDense(x.Dense)
+}
+
+// ScoreDense scores each row of x by the fraction of training rows that were
+// closer to their centroid than it is
+func (k *KMeans) ScoreDense(x *mat.Dense) ([]float64, error) {
+	if len(k.Centroids) == 0 {
+		return nil, fmt.Errorf("anomaly: kmeans has not been fit")
+	}
+	rows, cols := x.Dims()
+	if cols != len(k.Centroids[0]) {
+		return nil, fmt.Errorf("anomaly: kmeans was fit on %d columns, got %d", len(k.Centroids[0]), cols)
+	}
+	scores := make([]float64, rows)
+	for i := range scores {
+		_, d := k.nearest(mat.Row(nil, i, x))
+		scores[i] = float64(sort.SearchFloat64s(k.TrainDist, d)) / float64(len(k.TrainDist))
+	}
+	return scores, nil
+}
+
+func sqDist(a, b []float64) float64 {
+	var d float64
+	for j := range a {
+		d += (a[j] - b[j]) * (a[j] - b[j])
+	}
+	return d
+}
+
+func clone(x []float64) []float64 {
+	return append([]float64(nil), x...)
+}
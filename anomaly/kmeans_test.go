@@ -0,0 +1,109 @@
+package anomaly
+
+import (
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/andrewm4894/learn-go/features"
+	"gonum.org/v1/gonum/mat"
+)
+
+func fitKMeans(t *testing.T, x *mat.Dense, k int, seed int64) *KMeans {
+	t.Helper()
+	km := NewKMeans(k, 100, rand.New(rand.NewSource(seed)))
+	if err := km.FitDense(x); err != nil {
+		t.Fatal(err)
+	}
+	return km
+}
+
+func TestKMeansReproducible(t *testing.T) {
+	x := gaussianOutliers(t)
+	first := fitKMeans(t, x, 3, 42)
+	second := fitKMeans(t, x, 3, 42)
+	for c := range first.Centroids {
+		for j := range first.Centroids[c] {
+			if first.Centroids[c][j] != second.Centroids[c][j] {
+				t.Fatalf("centroid %d is %v then %v with the same seed", c, first.Centroids[c], second.Centroids[c])
+			}
+		}
+	}
+}
+
+func TestKMeansFindsOutliers(t *testing.T) {
+	// Fit on the normal rows only, then score them and the outliers
+	x := gaussianOutliers(t)
+	rows, cols := x.Dims()
+	normal := x.Slice(0, rows-10, 0, cols).(*mat.Dense)
+	km := fitKMeans(t, normal, 2, 42)
+	scores, err := km.ScoreDense(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	normalScores := append([]float64(nil), scores[:rows-10]...)
+	sort.Float64s(normalScores)
+	median := normalScores[len(normalScores)/2]
+	var outlierMean float64
+	for i, s := range scores[rows-10:] {
+		if s <= median {
+			t.Errorf("outlier %d scored %v, no more than the normal median %v", i, s, median)
+		}
+		outlierMean += s / 10
+	}
+	if outlierMean < 0.95 {
+		t.Errorf("outliers scored %v on average, want 0.95 or more", outlierMean)
+	}
+}
+
+func TestKMeansRows(t *testing.T) {
+	x := mat.NewDense(3, 2, []float64{0, 0, 1, 1, 5, 5})
+	if err := NewKMeans(4, 10, rand.New(rand.NewSource(1))).FitDense(x); err == nil {
+		t.Error("k of 4 on 3 rows: got no error")
+	}
+
+	// A centroid on every row, so every training row is at distance 0
+	km := fitKMeans(t, x, 3, 1)
+	for _, d := range km.TrainDist {
+		if d != 0 {
+			t.Errorf("training distances %v, want all 0", km.TrainDist)
+			break
+		}
+	}
+	scores, err := km.ScoreDense(mat.NewDense(2, 2, []float64{1, 1, 2, 3}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scores[0] != 0 || scores[1] != 1 {
+		t.Errorf("scores %v, want [0 1]", scores)
+	}
+}
+
+func TestKMeansSaveLoad(t *testing.T) {
+	x := gaussianOutliers(t)
+	km := fitKMeans(t, x, 2, 42)
+	want, err := km.ScoreDense(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "m.model.json")
+	if err := SaveModel(path, &Model{Key: "k", Detector: km}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Detector.Score(&features.Matrix{Dense: x})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d scored %v before saving and %v after loading", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package anomaly
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/andrewm4894/learn-go/features"
+)
+
+// ModelFormatVersion is bumped whenever the saved model format changes,
+// files with any other version are refused
+const ModelFormatVersion = 3
+
+// ErrNotSaveable is returned when saving or loading a detector that can't
+// be saved, like a golearn Forest
+var ErrNotSaveable = errors.New("anomaly: detector can't be saved")
+
+// FeatureConfig is how the features a model was trained on were made
+type FeatureConfig struct {
+	Lags            int                   `json:"lags"`
+	Diffs           int                   `json:"diffs"`
+	Smoothing       int                   `json:"smoothing"`
+	SmoothingMethod features.SmoothMethod `json:"smoothingMethod"`
+	Columns         []string              `json:"columns"`
+
+	// First and last timestamps of the rows the model was trained on
+	TrainStart int64 `json:"trainStart"`
+	TrainEnd   int64 `json:"trainEnd"`
+
+	// Points netdata averaged the training window down to and the seconds
+	// each one covers, rows of another size look different to the model
+	TrainPoints int   `json:"trainPoints"`
+	Step        int64 `json:"step"`
+
+	// Params are the detector's hyperparameters as json, however the caller
+	// likes to write them
+	Params json.RawMessage `json:"params"`
+}
+
+// Check returns an error saying what differs if current would make
+// different features to the ones the model was trained on, or asks for a
+// detector with different hyperparameters. Columns, the training window and
+// Params are only compared if current has them set.
+func (fc FeatureConfig) Check(current FeatureConfig) error {
+	if fc.Lags != current.Lags || fc.Diffs != current.Diffs ||
+		fc.Smoothing != current.Smoothing || fc.SmoothingMethod != current.SmoothingMethod {
+		return fmt.Errorf("anomaly: model features (lags %d, diffs %d, smoothing %d %s) do not match config (lags %d, diffs %d, smoothing %d %s)",
+			fc.Lags, fc.Diffs, fc.Smoothing, fc.SmoothingMethod,
+			current.Lags, current.Diffs, current.Smoothing, current.SmoothingMethod)
+	}
+	if fc.TrainPoints != current.TrainPoints || fc.Step != current.Step {
+		return fmt.Errorf("anomaly: model was trained on %d points of %ds but config wants %d points of %ds",
+			fc.TrainPoints, fc.Step, current.TrainPoints, current.Step)
+	}
+	if current.Columns != nil && !equalStrings(fc.Columns, current.Columns) {
+		return fmt.Errorf("anomaly: model columns %v do not match %v", fc.Columns, current.Columns)
+	}
+	if current.Params != nil && !bytes.Equal(fc.Params, current.Params) {
+		return fmt.Errorf("anomaly: model was trained with %s but config wants %s", fc.Params, current.Params)
+	}
+	return nil
+}
+
+// Model is a trained detector for one chart and the features it expects
+type Model struct {
+	Key      string
+	Features FeatureConfig
+	Detector Detector
+}
+
+// What gets written to disk. The checksum covers everything else so a
+// truncated or hand edited file is refused.
+type modelFile struct {
+	Version  int             `json:"version"`
+	Kind     string          `json:"kind"`
+	Key      string          `json:"key"`
+	Features json.RawMessage `json:"features"`
+	Payload  json.RawMessage `json:"payload"`
+	Checksum string          `json:"checksum"`
+}
+
+// ModelPath is where the model for key lives in dir. Bytes other than
+// letters, digits, '.', '_' and '-' are written as %XX, so every key gets
+// its own file and url.PathUnescape gets the key back.
+func ModelPath(dir, key string) string {
+	var name strings.Builder
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-' {
+			name.WriteByte(c)
+		} else {
+			fmt.Fprintf(&name, "%%%02X", c)
+		}
+	}
+	return filepath.Join(dir, name.String()+".model.json")
+}
+
+// SaveModel writes m to path, via a temp file so a crash never leaves a
+// half written model behind
+func SaveModel(path string, m *Model) error {
+	features, err := json.Marshal(m.Features)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(m.Detector)
+	if err != nil {
+		return err
+	}
+
+	f := modelFile{
+		Version:  ModelFormatVersion,
+		Kind:     m.Detector.Kind(),
+		Key:      m.Key,
+		Features: features,
+		Payload:  payload,
+	}
+	f.Checksum = f.checksum()
+	b, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadModel reads a model saved with SaveModel, checking its version and
+// checksum. The detector kind has to have been registered with Register.
+func LoadModel(path string) (*Model, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f modelFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("anomaly: %s: %w", path, err)
+	}
+	if f.Version != ModelFormatVersion {
+		return nil, fmt.Errorf("anomaly: %s: model format version %d, want %d", path, f.Version, ModelFormatVersion)
+	}
+	if f.Checksum != f.checksum() {
+		return nil, fmt.Errorf("anomaly: %s: checksum mismatch", path)
+	}
+	detector, err := newDetector(f.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, path)
+	}
+
+	m := &Model{Key: f.Key, Detector: detector}
+	if err := json.Unmarshal(f.Features, &m.Features); err != nil {
+		return nil, fmt.Errorf("anomaly: %s: features: %w", path, err)
+	}
+	if err := json.Unmarshal(f.Payload, m.Detector); err != nil {
+		return nil, fmt.Errorf("anomaly: %s: %s: %w", path, f.Kind, err)
+	}
+	return m, nil
+}
+
+func (f modelFile) checksum() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n%s\n%s\n", f.Version, f.Kind, f.Key)
+	h.Write(f.Features)
+	h.Write([]byte("\n"))
+	h.Write(f.Payload)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/andrewm4894/learn-go/features"
+)
+
+func TestSaveLoadModel(t *testing.T) {
+	x := gaussianOutliers(t)
+	f, want := fitIForest(t, x, 42)
+	path := filepath.Join(t.TempDir(), "m.model.json")
+	if err := SaveModel(path, &Model{Key: "k", Detector: f}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.Detector.(*IForest).ScoreDense(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d scored %v before saving and %v after loading", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSaveForestNotSaveable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "m.model.json")
+	err := SaveModel(path, &Model{Key: "k", Detector: NewForest(10, 10, 100)})
+	if !errors.Is(err, ErrNotSaveable) {
+		t.Errorf("saving a golearn forest gave %v, want ErrNotSaveable", err)
+	}
+}
+
+func TestModelPath(t *testing.T) {
+	keys := []string{"a|b_c", "a_b|c", "a%7Cb_c", "london.my-netdata.io|system.cpu", "localhost:19999|disk/sda"}
+	seen := make(map[string]string)
+	for _, key := range keys {
+		path := ModelPath("models", key)
+		if filepath.Dir(path) != "models" {
+			t.Errorf("%q saves outside the model dir, to %v", key, path)
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".model.json")
+		if got, err := url.PathUnescape(name); err != nil || got != key {
+			t.Errorf("%q saves to %v, which unescapes to %q, %v", key, path, got, err)
+		}
+		if other, ok := seen[path]; ok {
+			t.Errorf("%q and %q both save to %v", key, other, path)
+		}
+		seen[path] = key
+	}
+	if got := ModelPath("", "london.my-netdata.io|system.cpu"); got != "london.my-netdata.io%7Csystem.cpu.model.json" {
+		t.Errorf("got %v", got)
+	}
+}
+
+func TestFeatureConfigCheck(t *testing.T) {
+	saved := FeatureConfig{
+		Lags:            2,
+		Smoothing:       3,
+		SmoothingMethod: features.Mean,
+		Columns:         []string{"user", "system"},
+		TrainStart:      100,
+		TrainEnd:        200,
+		TrainPoints:     100,
+		Step:            36,
+		Params:          json.RawMessage(`{"nTrees":100}`),
+	}
+	tests := []struct {
+		name   string
+		change func(fc *FeatureConfig)
+		ok     bool
+	}{
+		{"same", func(fc *FeatureConfig) {}, true},
+		{"no columns or params to compare", func(fc *FeatureConfig) { fc.Columns, fc.Params = nil, nil }, true},
+		{"other training window", func(fc *FeatureConfig) { fc.TrainStart, fc.TrainEnd = 300, 400 }, true},
+		{"lags", func(fc *FeatureConfig) { fc.Lags = 1 }, false},
+		{"diffs", func(fc *FeatureConfig) { fc.Diffs = 1 }, false},
+		{"smoothing", func(fc *FeatureConfig) { fc.SmoothingMethod = features.EWMA }, false},
+		{"columns", func(fc *FeatureConfig) { fc.Columns = []string{"user"} }, false},
+		{"train points", func(fc *FeatureConfig) { fc.TrainPoints = 200 }, false},
+		{"step", func(fc *FeatureConfig) { fc.Step = 72 }, false},
+		{"params", func(fc *FeatureConfig) { fc.Params = json.RawMessage(`{"nTrees":10}`) }, false},
+	}
+	for _, tt := range tests {
+		current := saved
+		tt.change(&current)
+		if err := saved.Check(current); (err == nil) != tt.ok {
+			t.Errorf("%s: got %v", tt.name, err)
+		}
+	}
+
+	// Params survive a save and load
+	path := filepath.Join(t.TempDir(), "m.model.json")
+	f, _ := fitIForest(t, gaussianOutliers(t), 42)
+	if err := SaveModel(path, &Model{Key: "k", Features: saved, Detector: f}); err != nil {
+		t.Fatal(err)
+	}
+	m, err := LoadModel(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Features.Check(saved); err != nil {
+		t.Error(err)
+	}
+}
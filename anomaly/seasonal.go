@@ -0,0 +1,280 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/features"
+)
+
+// SeasonalKind is the Kind of a Seasonal
+const SeasonalKind = "seasonal"
+
+func init() {
+	Register(SeasonalKind, func() Detector { return &Seasonal{} })
+}
+
+// Seasonal splits each column into trend, seasonal and residual parts, in
+// the spirit of STL, and scores how unusual the residual is. It needs a long
+// training window to learn the seasonal profiles from, at least the shortest
+// season and better two of the longest, as with only one every point fits
+// its profile exactly. The data can be coarse since rows are placed in a
+// season by their timestamps rather than by counting rows.
+//
+// In training the profiles are learnt from the data less its mean over the
+// longest season centred on each point, which averages any seasonality out.
+// Once they are taken out the trend at each point is the mean of the
+// deseasonalised values in the TrendWindow seconds before it, so scoring
+// carries on from the end of the training data. Residuals are scored like
+// Baseline's mad method, a residual Threshold robust standard deviations out
+// scores 0.5.
+type Seasonal struct {
+	// Season lengths in seconds, like 86400 for daily and 604800 for weekly
+	Seasons []int64 `json:"seasons"`
+
+	// Seconds of each season averaged into one point of its profile
+	Resolution int64 `json:"resolution"`
+
+	TrendWindow int64   `json:"trendWindow"`
+	Threshold   float64 `json:"threshold"`
+
+	// Seasonal profile of each column for each season, Profiles[s][j][bin]
+	Profiles [][][]float64 `json:"profiles"`
+
+	// Robust standard deviation of each column's residuals in training
+	Spread []float64 `json:"spread"`
+
+	// Deseasonalised values of each column from the end of the training
+	// data, enough to start the trend off when scoring
+	HistTimes []int64     `json:"histTimes"`
+	History   [][]float64 `json:"history"`
+}
+
+// NewSeasonal makes an untrained seasonal detector, all times in seconds
+func NewSeasonal(seasons []int64, resolution, trendWindow int64, threshold float64) *Seasonal {
+	return &Seasonal{Seasons: seasons, Resolution: resolution, TrendWindow: trendWindow, Threshold: threshold}
+}
+
+// Kind implements Detector
+func (s *Seasonal) Kind() string {
+	return SeasonalKind
+}
+
+// Fit implements Detector
+func (s *Seasonal) Fit(x *features.Matrix) error {
+	if len(s.Seasons) == 0 {
+		return fmt.Errorf("anomaly: seasonal needs at least one season")
+	}
+	shortest, longest := s.Seasons[0], s.Seasons[0]
+	for _, season := range s.Seasons {
+		if season < 1 {
+			return fmt.Errorf("anomaly: seasonal season must be >= 1 second, got %d", season)
+		}
+		if season < shortest {
+			shortest = season
+		}
+		if season > longest {
+			longest = season
+		}
+	}
+	if s.Resolution < 1 || s.TrendWindow < 1 || s.Threshold <= 0 {
+		return fmt.Errorf("anomaly: seasonal needs resolution and trend window >= 1 and threshold > 0")
+	}
+	rows, cols := x.Dims()
+	if rows < 2 {
+		return fmt.Errorf("anomaly: seasonal needs at least 2 rows, got %d", rows)
+	}
+	if span := x.Times[rows-1] - x.Times[0]; span < shortest {
+		return fmt.Errorf("anomaly: seasonal needs at least %ds of training data, got %ds", shortest, span)
+	}
+
+	s.Profiles = make([][][]float64, len(s.Seasons))
+	for k := range s.Profiles {
+		s.Profiles[k] = make([][]float64, cols)
+	}
+	s.Spread = make([]float64, cols)
+	s.History = make([][]float64, cols)
+
+	for j := 0; j < cols; j++ {
+		y := make([]float64, rows)
+		for i := range y {
+			y[i] = x.At(i, j)
+		}
+
+		// Alternate between estimating the trend with the seasonality taken
+		// out and the seasonality with the trend taken out
+		seasonal := make([]float64, rows)
+		deseason := make([]float64, rows)
+		for iter := 0; iter < 2; iter++ {
+			for i := range y {
+				deseason[i] = y[i] - seasonal[i]
+			}
+			trend, full := centredMean(x.Times, deseason, longest)
+
+			resid := make([]float64, rows)
+			for i := range y {
+				resid[i] = y[i] - trend[i]
+				seasonal[i] = 0
+			}
+			for k, season := range s.Seasons {
+				profile := s.profile(x.Times, resid, season, full)
+				s.Profiles[k][j] = profile
+				for i, t := range x.Times {
+					v := profile[s.bin(t, season)]
+					resid[i] -= v
+					seasonal[i] += v
+				}
+			}
+		}
+
+		for i := range y {
+			deseason[i] = y[i] - seasonal[i]
+		}
+		trend := s.trend(x.Times, deseason)
+		resid := make([]float64, rows)
+		for i := range y {
+			resid[i] = deseason[i] - trend[i]
+		}
+		s.Spread[j] = robustSpread(resid, quantile(resid, 0.5))
+
+		s.History[j] = deseason
+	}
+
+	// Only keep what the trend of the next point needs
+	start := 0
+	for start < rows-1 && x.Times[start] < x.Times[rows-1]-s.TrendWindow {
+		start++
+	}
+	s.HistTimes = append([]int64(nil), x.Times[start:]...)
+	for j := range s.History {
+		s.History[j] = clone(s.History[j][start:])
+	}
+	return nil
+}
+
+// Mean of the values in the TrendWindow seconds before each point, or the
+// point itself if there are none
+func (s *Seasonal) trend(times []int64, values []float64) []float64 {
+	trend := make([]float64, len(values))
+	var sum float64
+	lo := 0
+	for i, t := range times {
+		for lo < i && times[lo] < t-s.TrendWindow {
+			sum -= values[lo]
+			lo++
+		}
+		if i > lo {
+			trend[i] = sum / float64(i-lo)
+		} else {
+			trend[i] = values[i]
+		}
+		sum += values[i]
+	}
+	return trend
+}
+
+// Mean of the values in the window seconds centred on each point, and
+// whether each point had the whole window. If none did every point is
+// marked as having it, as a part window is better than nothing.
+func centredMean(times []int64, values []float64, window int64) ([]float64, []bool) {
+	sums := make([]float64, len(values)+1)
+	for i, v := range values {
+		sums[i+1] = sums[i] + v
+	}
+	mean := make([]float64, len(values))
+	full := make([]bool, len(values))
+	found := false
+	first, last := times[0], times[len(times)-1]
+	for i, t := range times {
+		lo := sort.Search(len(times), func(k int) bool { return times[k] >= t-window/2 })
+		hi := sort.Search(len(times), func(k int) bool { return times[k] >= t-window/2+window })
+		mean[i] = (sums[hi] - sums[lo]) / float64(hi-lo)
+		full[i] = t-window/2 >= first && t-window/2+window <= last+1
+		found = found || full[i]
+	}
+	if !found {
+		for i := range full {
+			full[i] = true
+		}
+	}
+	return mean, full
+}
+
+// Mean of the values used in each bin of season, centred on zero
+func (s *Seasonal) profile(times []int64, values []float64, season int64, use []bool) []float64 {
+	n := (season + s.Resolution - 1) / s.Resolution
+	sums := make([]float64, n)
+	counts := make([]int, n)
+	for i, t := range times {
+		if !use[i] {
+			continue
+		}
+		b := s.bin(t, season)
+		sums[b] += values[i]
+		counts[b]++
+	}
+	var total float64
+	var filled int
+	for b := range sums {
+		if counts[b] > 0 {
+			sums[b] /= float64(counts[b])
+			total += sums[b]
+			filled++
+		}
+	}
+	for b := range sums {
+		if counts[b] > 0 {
+			sums[b] -= total / float64(filled)
+		}
+	}
+	return sums
+}
+
+// Which bin of season t falls in
+func (s *Seasonal) bin(t, season int64) int {
+	phase := t % season
+	if phase < 0 {
+		phase += season
+	}
+	return int(phase / s.Resolution)
+}
+
+// Score implements Detector
+func (s *Seasonal) Score(x *features.Matrix) ([]float64, error) {
+	if s.Spread == nil {
+		return nil, fmt.Errorf("anomaly: seasonal has not been fit")
+	}
+	rows, cols := x.Dims()
+	if cols != len(s.Spread) {
+		return nil, fmt.Errorf("anomaly: seasonal was fit on %d columns, got %d", len(s.Spread), cols)
+	}
+
+	// History from before the data being scored, with the scored rows added
+	// on as they go
+	n := 0
+	for n < len(s.HistTimes) && s.HistTimes[n] < x.Times[0] {
+		n++
+	}
+	times := append(append([]int64(nil), s.HistTimes[:n]...), x.Times...)
+
+	scores := make([]float64, rows)
+	for j := 0; j < cols; j++ {
+		values := append(clone(s.History[j][:n]), make([]float64, rows)...)
+		for i := 0; i < rows; i++ {
+			v := x.At(i, j)
+			for k, season := range s.Seasons {
+				v -= s.Profiles[k][j][s.bin(x.Times[i], season)]
+			}
+			values[n+i] = v
+		}
+		trend := s.trend(times, values)
+		for i := 0; i < rows; i++ {
+			d := spreads(values[n+i]-trend[n+i], s.Spread[j], trend[n+i])
+			if score := 1 - math.Pow(2, -d/s.Threshold); score > scores[i] {
+				scores[i] = score
+			}
+		}
+	}
+	return scores, nil
+}
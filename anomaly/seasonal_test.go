@@ -0,0 +1,133 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andrewm4894/learn-go/features"
+	"gonum.org/v1/gonum/mat"
+)
+
+const day = 86400
+
+// A daily sine wave between 5 and 15 with a little noise, a row every 5
+// minutes for days days from t=0, or from start if given
+func dailySeries(rng *rand.Rand, start int64, days int) *features.Matrix {
+	n := days * day / 300
+	times := make([]int64, n)
+	values := make([]float64, n)
+	for i := range times {
+		times[i] = start + int64(300*i)
+		values[i] = 10 + 5*math.Sin(2*math.Pi*float64(times[i])/day) + 0.2*rng.NormFloat64()
+	}
+	return &features.Matrix{Dense: mat.NewDense(n, 1, values), Names: []string{"v"}, Times: times}
+}
+
+func fitSeasonal(t *testing.T) *Seasonal {
+	t.Helper()
+	s := NewSeasonal([]int64{day}, 600, 3600, 3)
+	if err := s.Fit(dailySeries(rand.New(rand.NewSource(1)), 0, 7)); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSeasonalProfile(t *testing.T) {
+	s := fitSeasonal(t)
+
+	// The profile is the sine wave, centred on 0
+	profile := s.Profiles[0][0]
+	if len(profile) != day/600 {
+		t.Fatalf("got %d bins, want %d", len(profile), day/600)
+	}
+	for b, v := range profile {
+		mid := float64(b*600 + 300)
+		if want := 5 * math.Sin(2*math.Pi*mid/day); math.Abs(v-want) > 0.5 {
+			t.Errorf("bin %d = %v, want about %v", b, v, want)
+		}
+	}
+	if s.Spread[0] > 0.5 {
+		t.Errorf("residual spread %v, want about the 0.2 of noise", s.Spread[0])
+	}
+}
+
+func TestSeasonalFindsResidualSpike(t *testing.T) {
+	s := fitSeasonal(t)
+
+	// The day after training, with a spike well inside the series' usual
+	// range planted at the trough
+	x := dailySeries(rand.New(rand.NewSource(2)), 7*day, 1)
+	trough := 3 * day / 4 / 300
+	x.Set(trough, 0, 12)
+	scores, err := s.Score(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The spike pulls up the trend for the TrendWindow after it, so the
+	// rows then are left out
+	var flagged int
+	for i, score := range scores {
+		if (i < trough || i > trough+3600/300) && score >= 0.5 {
+			flagged++
+		}
+	}
+	if flagged > len(scores)/50 {
+		t.Errorf("%d of %d normal rows scored 0.5 or more", flagged, len(scores))
+	}
+	if scores[trough] < 0.9 {
+		t.Errorf("spike at the trough scored %v, want 0.9 or more", scores[trough])
+	}
+
+	// The same value at the peak is usual
+	peak := day / 4 / 300
+	if scores[peak] >= 0.5 {
+		t.Errorf("peak scored %v, want under 0.5", scores[peak])
+	}
+}
+
+func TestSeasonalSaveLoad(t *testing.T) {
+	s := fitSeasonal(t)
+	x := dailySeries(rand.New(rand.NewSource(2)), 7*day, 1)
+	want, err := s.Score(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var loaded Seasonal
+	if err := json.Unmarshal(b, &loaded); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loaded.Score(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("row %d scored %v before saving and %v after loading", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSeasonalErrors(t *testing.T) {
+	x := dailySeries(rand.New(rand.NewSource(1)), 0, 1)
+	for name, s := range map[string]*Seasonal{
+		"no seasons":      NewSeasonal(nil, 600, 3600, 3),
+		"shorter than":    NewSeasonal([]int64{2 * day}, 600, 3600, 3),
+		"zero resolution": NewSeasonal([]int64{day}, 0, 3600, 3),
+		"zero threshold":  NewSeasonal([]int64{day}, 600, 3600, 0),
+	} {
+		if err := s.Fit(x); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+	if _, err := NewSeasonal([]int64{day}, 600, 3600, 3).Score(x); err == nil {
+		t.Error("scoring before fitting: got no error")
+	}
+}
@@ -0,0 +1,59 @@
+// Package anomaly holds anomaly scores and the detectors that make them
+package anomaly
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Point is an anomaly score at a unix timestamp
+type Point struct {
+	Time  int64
+	Score float64
+}
+
+// Series is the anomaly scores for one chart, oldest first
+type Series struct {
+	Key    string
+	Points []Point
+}
+
+// NewSeries pairs up scores with the timestamps of the rows they came from
+func NewSeries(key string, times []int64, scores []float64) (Series, error) {
+	if len(times) != len(scores) {
+		return Series{}, fmt.Errorf("anomaly: %s has %d timestamps but %d scores", key, len(times), len(scores))
+	}
+	s := Series{Key: key, Points: make([]Point, len(scores))}
+	for i := range scores {
+		s.Points[i] = Point{Time: times[i], Score: scores[i]}
+	}
+	return s, nil
+}
+
+// Latest returns the most recent score, ok is false if there are none
+func (s Series) Latest() (p Point, ok bool) {
+	if len(s.Points) == 0 {
+		return Point{}, false
+	}
+	return s.Points[len(s.Points)-1], true
+}
+
+// At returns the score at time t, ok is false if there is none
+func (s Series) At(t int64) (score float64, ok bool) {
+	for _, p := range s.Points {
+		if p.Time == t {
+			return p.Score, true
+		}
+	}
+	return 0, false
+}
+
+// String prints the series as "key: time=score time=score ..."
+func (s Series) String() string {
+	var b strings.Builder
+	b.WriteString(s.Key + ":")
+	for _, p := range s.Points {
+		fmt.Fprintf(&b, " %d=%.4f", p.Time, p.Score)
+	}
+	return b.String()
+}
@@ -0,0 +1,690 @@
+// Package config loads the settings for the anomaly detection scripts
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/andrewm4894/learn-go/anomaly"
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+// Config is the top level of a config file
+type Config struct {
+	// NSteps is how many steps to run for
+	NSteps int `json:"nSteps"`
+
+	// TrainEvery is how often, in steps, to retrain models
+	TrainEvery int `json:"trainEvery"`
+
+	// StepInterval is how long to sleep between steps
+	StepInterval Duration `json:"stepInterval"`
+
+	// Timeouts for a single request, a whole training fetch and a whole scoring step
+	RequestTimeout Duration `json:"requestTimeout"`
+	TrainTimeout   Duration `json:"trainTimeout"`
+	StepTimeout    Duration `json:"stepTimeout"`
+
+	// Pool sizes the worker pool used for all fetches
+	Pool netdata.PoolOptions `json:"pool"`
+
+	// ModelDir is where trained models are saved and loaded from at
+	// startup, models are only kept in memory if empty
+	ModelDir string `json:"modelDir"`
+
+	// Replay, if set, gets data from saved responses instead of the api
+	Replay *Replay `json:"replay"`
+
+	Hosts []Host `json:"hosts"`
+}
+
+// Replay says where saved responses are and how fast to play them back, see
+// netdata.Replay
+type Replay struct {
+	// Dir is a directory of saved responses or an archive from the recorder
+	Dir   string  `json:"dir"`
+	Speed float64 `json:"speed"`
+
+	// Start is the unix time to start the replay's clock at, if 0 it starts
+	// Warmup after the first saved row
+	Start  int64    `json:"start"`
+	Warmup Duration `json:"warmup"`
+}
+
+// Source makes the netdata.Replay the config asks for
+func (r Replay) Source() (*netdata.Replay, error) {
+	return netdata.NewReplay(r.Dir, r.Speed, r.Start, seconds(r.Warmup))
+}
+
+// Host is a netdata host and the charts on it to model
+type Host struct {
+	Host string `json:"host"`
+
+	// Charts lists chart ids to model, if empty charts are discovered
+	// on the host and picked out with Filter
+	Charts []string            `json:"charts"`
+	Filter netdata.ChartFilter `json:"filter"`
+
+	// Retry overrides netdata.DefaultRetryPolicy for this host
+	Retry *Retry `json:"retry"`
+
+	// Settings used for every chart on the host unless overridden
+	Settings
+
+	// Overrides change some settings for charts matching a pattern,
+	// applied in order
+	Overrides []Override `json:"overrides"`
+
+	// Groups are sets of charts modelled together, on top of each chart's
+	// own model
+	Groups []Group `json:"groups"`
+}
+
+// Settings are the training, feature and model settings for a chart
+type Settings struct {
+	TrainAfter  int64 `json:"trainAfter"`
+	TrainBefore int64 `json:"trainBefore"`
+
+	// TrainPoints asks netdata to average the training window down to this
+	// many points, 0 gets every point. Handy for long windows. Scoring asks
+	// for points of the same size, see TrainStep, so the window has to be a
+	// fixed length: relative, or absolute at both ends.
+	TrainPoints int `json:"trainPoints"`
+
+	Lags  int `json:"lags"`
+	Diffs int `json:"diffs"`
+
+	// Smoothing is the window size, SmoothingMethod one of "mean", "ewma" or "median"
+	Smoothing       int                   `json:"smoothing"`
+	SmoothingMethod features.SmoothMethod `json:"smoothingMethod"`
+
+	Model Model `json:"model"`
+}
+
+// Model picks the anomaly detector to use and holds its hyperparameters,
+// only the ones for Type are used
+type Model struct {
+	// Type is the kind of detector, "iforest", "golearn-iforest", "kmeans",
+	// one of the cheap per dimension ones "zscore", "mad" or "percentile", or
+	// "seasonal". golearn-iforest models can't be saved to modelDir, they
+	// are retrained every run.
+	Type string `json:"type"`
+
+	// Isolation forests, both kinds
+	NTrees   int `json:"nTrees"`
+	MaxDepth int `json:"maxDepth"`
+
+	// golearn isolation forest
+	SubSpace int `json:"subSpace"`
+
+	// Native isolation forest, 0 for sampleSize, maxDepth or maxFeatures
+	// leaves it to pick
+	SampleSize  int `json:"sampleSize"`
+	MaxFeatures int `json:"maxFeatures"`
+
+	// K-means
+	K             int `json:"k"`
+	MaxIterations int `json:"maxIterations"`
+
+	// zscore, mad and percentile, see anomaly.Baseline
+	Window     int     `json:"window"`
+	Threshold  float64 `json:"threshold"`
+	Percentile float64 `json:"percentile"`
+
+	// Seasonal, Threshold is used too
+	Seasons     []Duration `json:"seasons"`
+	Resolution  Duration   `json:"resolution"`
+	TrendWindow Duration   `json:"trendWindow"`
+
+	// Seed for the models that use randomness other than golearn's, the same
+	// seed gives the same model on the same data
+	Seed int64 `json:"seed"`
+}
+
+// Detector makes a new, untrained detector as configured
+func (m Model) Detector() (anomaly.Detector, error) {
+	switch m.Type {
+	case anomaly.ForestKind:
+		return anomaly.NewForest(m.NTrees, m.MaxDepth, m.SubSpace), nil
+	case anomaly.IForestKind:
+		rng := rand.New(rand.NewSource(m.Seed))
+		return anomaly.NewIForest(m.NTrees, m.SampleSize, m.MaxDepth, m.MaxFeatures, rng), nil
+	case anomaly.KMeansKind:
+		rng := rand.New(rand.NewSource(m.Seed))
+		return anomaly.NewKMeans(m.K, m.MaxIterations, rng), nil
+	case anomaly.ZScoreKind, anomaly.MADKind, anomaly.PercentileKind:
+		return anomaly.NewBaseline(m.Type, m.Window, m.Threshold, m.Percentile), nil
+	case anomaly.SeasonalKind:
+		seasons := make([]int64, len(m.Seasons))
+		for i, season := range m.Seasons {
+			seasons[i] = seconds(season)
+		}
+		return anomaly.NewSeasonal(seasons, seconds(m.Resolution), seconds(m.TrendWindow), m.Threshold), nil
+	}
+	return nil, fmt.Errorf("config: unknown model type %q", m.Type)
+}
+
+// Params are the hyperparameters Type uses as json, keys sorted, so a saved
+// model can be checked against the config
+func (m Model) Params() json.RawMessage {
+	var params map[string]interface{}
+	switch m.Type {
+	case anomaly.ForestKind:
+		params = map[string]interface{}{"nTrees": m.NTrees, "maxDepth": m.MaxDepth, "subSpace": m.SubSpace}
+	case anomaly.IForestKind:
+		params = map[string]interface{}{"nTrees": m.NTrees, "sampleSize": m.SampleSize, "maxDepth": m.MaxDepth, "maxFeatures": m.MaxFeatures, "seed": m.Seed}
+	case anomaly.KMeansKind:
+		params = map[string]interface{}{"k": m.K, "maxIterations": m.MaxIterations, "seed": m.Seed}
+	case anomaly.ZScoreKind, anomaly.MADKind:
+		params = map[string]interface{}{"window": m.Window, "threshold": m.Threshold}
+	case anomaly.PercentileKind:
+		params = map[string]interface{}{"window": m.Window, "threshold": m.Threshold, "percentile": m.Percentile}
+	case anomaly.SeasonalKind:
+		params = map[string]interface{}{"seasons": m.Seasons, "resolution": m.Resolution, "trendWindow": m.TrendWindow, "threshold": m.Threshold}
+	}
+	// Only ints, floats and durations, which always marshal
+	b, _ := json.Marshal(params)
+	return b
+}
+
+// PerDimension reports whether the model scores each column against its own
+// past, as the baselines and seasonal do, rather than rows as a whole
+func (m Model) PerDimension() bool {
+	switch m.Type {
+	case anomaly.ZScoreKind, anomaly.MADKind, anomaly.PercentileKind, anomaly.SeasonalKind:
+		return true
+	}
+	return false
+}
+
+func (m Model) problems() []string {
+	var problems []string
+	switch m.Type {
+	case anomaly.ForestKind:
+		if m.NTrees < 1 {
+			problems = append(problems, fmt.Sprintf("nTrees must be >= 1, got %d", m.NTrees))
+		}
+		if m.MaxDepth < 1 {
+			problems = append(problems, fmt.Sprintf("maxDepth must be >= 1, got %d", m.MaxDepth))
+		}
+		if m.SubSpace < 1 {
+			problems = append(problems, fmt.Sprintf("subSpace must be >= 1, got %d", m.SubSpace))
+		}
+	case anomaly.IForestKind:
+		if m.NTrees < 1 {
+			problems = append(problems, fmt.Sprintf("nTrees must be >= 1, got %d", m.NTrees))
+		}
+		if m.MaxDepth < 0 {
+			problems = append(problems, fmt.Sprintf("maxDepth must be >= 0, got %d", m.MaxDepth))
+		}
+		if m.SampleSize < 0 {
+			problems = append(problems, fmt.Sprintf("sampleSize must be >= 0, got %d", m.SampleSize))
+		}
+		if m.MaxFeatures < 0 {
+			problems = append(problems, fmt.Sprintf("maxFeatures must be >= 0, got %d", m.MaxFeatures))
+		}
+	case anomaly.KMeansKind:
+		if m.K < 1 {
+			problems = append(problems, fmt.Sprintf("k must be >= 1, got %d", m.K))
+		}
+		if m.MaxIterations < 1 {
+			problems = append(problems, fmt.Sprintf("maxIterations must be >= 1, got %d", m.MaxIterations))
+		}
+	case anomaly.ZScoreKind, anomaly.MADKind, anomaly.PercentileKind:
+		if m.Window < 0 {
+			problems = append(problems, fmt.Sprintf("window must be >= 0, got %d", m.Window))
+		}
+		if m.Threshold <= 0 {
+			problems = append(problems, fmt.Sprintf("threshold must be > 0, got %v", m.Threshold))
+		}
+		if m.Type == anomaly.PercentileKind && (m.Percentile <= 50 || m.Percentile > 100) {
+			problems = append(problems, fmt.Sprintf("percentile must be > 50 and <= 100, got %v", m.Percentile))
+		}
+	case anomaly.SeasonalKind:
+		if len(m.Seasons) == 0 {
+			problems = append(problems, "seasons must list at least one season")
+		}
+		for _, season := range m.Seasons {
+			if seconds(season) < 1 {
+				problems = append(problems, fmt.Sprintf("seasons must be at least 1s, got %v", time.Duration(season)))
+			}
+		}
+		if seconds(m.Resolution) < 1 {
+			problems = append(problems, fmt.Sprintf("resolution must be at least 1s, got %v", time.Duration(m.Resolution)))
+		}
+		if seconds(m.TrendWindow) < 1 {
+			problems = append(problems, fmt.Sprintf("trendWindow must be at least 1s, got %v", time.Duration(m.TrendWindow)))
+		}
+		if m.Threshold <= 0 {
+			problems = append(problems, fmt.Sprintf("threshold must be > 0, got %v", m.Threshold))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("type %q is not a known model type", m.Type))
+	}
+	return problems
+}
+
+// Override sets some of the Settings for charts whose id matches Match
+type Override struct {
+	Match    string          `json:"match"`
+	Settings json.RawMessage `json:"settings"`
+}
+
+// Group is a set of charts joined on time and fed to one detector, so
+// anomalies that show up across several charts at once can be caught. Its
+// columns are named "chart|dimension".
+type Group struct {
+	// Name stands in for the chart id in model keys and output, so should
+	// not clash with one
+	Name   string               `json:"name"`
+	Charts []string             `json:"charts"`
+	Join   features.JoinOptions `json:"join"`
+
+	// Settings change some of the host's settings for the group, like an
+	// override's
+	Settings json.RawMessage `json:"settings"`
+}
+
+// DefaultJoin is how groups are joined when they don't say
+var DefaultJoin = features.JoinOptions{Align: features.Outer, Fill: features.FFill}
+
+// Retry is the config file form of a netdata.RetryPolicy
+type Retry struct {
+	MaxAttempts int      `json:"maxAttempts"`
+	BaseDelay   Duration `json:"baseDelay"`
+	MaxDelay    Duration `json:"maxDelay"`
+	Jitter      float64  `json:"jitter"`
+	RetryStatus []int    `json:"retryStatus"`
+}
+
+// Duration is a time.Duration written as a string like "500ms" or "2s"
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("duration should be a string like \"2s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Whole seconds in d, netdata timestamps are in seconds
+func seconds(d Duration) int64 {
+	return int64(time.Duration(d) / time.Second)
+}
+
+// MarshalJSON writes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Default returns the settings the scripts used before there was a config file
+func Default() *Config {
+	return &Config{
+		NSteps:         30,
+		TrainEvery:     15,
+		StepInterval:   Duration(500 * time.Millisecond),
+		RequestTimeout: Duration(2 * time.Second),
+		TrainTimeout:   Duration(10 * time.Second),
+		StepTimeout:    Duration(1 * time.Second),
+		Pool:           netdata.PoolOptions{Workers: 8, PerHost: 4, QueueSize: 100},
+	}
+}
+
+// DefaultSettings are used for any chart setting left out of a host
+var DefaultSettings = Settings{
+	TrainAfter:      -100,
+	TrainBefore:     0,
+	Lags:            1,
+	Diffs:           0,
+	Smoothing:       2,
+	SmoothingMethod: features.Mean,
+	Model: Model{
+		Type:          anomaly.ForestKind,
+		NTrees:        10,
+		MaxDepth:      10,
+		SubSpace:      100,
+		K:             2,
+		MaxIterations: 100,
+		Threshold:     3,
+		Percentile:    99,
+		Seasons:       []Duration{Duration(24 * time.Hour)},
+		Resolution:    Duration(5 * time.Minute),
+		TrendWindow:   Duration(time.Hour),
+	},
+}
+
+// Load reads the config file at path on top of Default and validates it
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	c, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Parse decodes and validates a json config. Unknown keys are an error so
+// typos are caught up front.
+func Parse(b []byte) (*Config, error) {
+	c := Default()
+
+	// Decode hosts separately so each one starts from DefaultSettings
+	var raw struct {
+		Config
+		Hosts []json.RawMessage `json:"hosts"`
+	}
+	raw.Config = *c
+	if err := decodeStrict(b, &raw); err != nil {
+		return nil, err
+	}
+	*c = raw.Config
+	c.Hosts = make([]Host, len(raw.Hosts))
+	for i, hostBytes := range raw.Hosts {
+		c.Hosts[i].Settings = DefaultSettings.clone()
+		if err := decodeStrict(hostBytes, &c.Hosts[i]); err != nil {
+			return nil, fmt.Errorf("hosts[%d]: %w", i, err)
+		}
+		for j := range c.Hosts[i].Groups {
+			join := &c.Hosts[i].Groups[j].Join
+			if join.Align == "" {
+				join.Align = DefaultJoin.Align
+			}
+			if join.Fill == "" {
+				join.Fill = DefaultJoin.Fill
+			}
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func decodeStrict(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Validate checks the whole config, reporting every problem it finds
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.NSteps < 0 {
+		add("nSteps must be >= 0, got %d", c.NSteps)
+	}
+	if c.TrainEvery < 1 {
+		add("trainEvery must be >= 1, got %d", c.TrainEvery)
+	}
+	for _, d := range []struct {
+		name string
+		d    Duration
+	}{
+		{"requestTimeout", c.RequestTimeout},
+		{"trainTimeout", c.TrainTimeout},
+		{"stepTimeout", c.StepTimeout},
+	} {
+		if d.d <= 0 {
+			add("%s must be > 0, got %v", d.name, time.Duration(d.d))
+		}
+	}
+	if c.StepInterval < 0 {
+		add("stepInterval must be >= 0, got %v", time.Duration(c.StepInterval))
+	}
+	if c.Pool.Workers < 1 {
+		add("pool.workers must be >= 1, got %d", c.Pool.Workers)
+	}
+	if c.Replay != nil {
+		if c.Replay.Dir == "" {
+			add("replay.dir is empty")
+		}
+		if c.Replay.Speed <= 0 {
+			add("replay.speed must be > 0, got %v", c.Replay.Speed)
+		}
+		if c.Replay.Warmup < 0 {
+			add("replay.warmup must be >= 0, got %v", time.Duration(c.Replay.Warmup))
+		}
+	}
+	if len(c.Hosts) == 0 {
+		add("no hosts")
+	}
+	for i, h := range c.Hosts {
+		prefix := fmt.Sprintf("hosts[%d]", i)
+		if h.Host == "" {
+			add("%s.host is empty", prefix)
+		}
+		if err := h.Filter.Validate(); err != nil {
+			add("%s.filter: %v", prefix, err)
+		}
+		if r := h.Retry; r != nil {
+			if r.MaxAttempts < 1 {
+				add("%s.retry.maxAttempts must be >= 1, got %d", prefix, r.MaxAttempts)
+			}
+			if r.BaseDelay < 0 {
+				add("%s.retry.baseDelay must be >= 0, got %v", prefix, time.Duration(r.BaseDelay))
+			}
+			if r.MaxDelay < 0 {
+				add("%s.retry.maxDelay must be >= 0, got %v", prefix, time.Duration(r.MaxDelay))
+			}
+			if r.Jitter < 0 || r.Jitter > 1 {
+				add("%s.retry.jitter must be from 0 to 1, got %v", prefix, r.Jitter)
+			}
+			for _, code := range r.RetryStatus {
+				if code < 100 || code > 599 {
+					add("%s.retry.retryStatus has %d, which is not an http status", prefix, code)
+				}
+			}
+		}
+		for _, msg := range h.Settings.problems() {
+			add("%s.%s", prefix, msg)
+		}
+		for j, o := range h.Overrides {
+			oprefix := fmt.Sprintf("%s.overrides[%d]", prefix, j)
+			if _, err := path.Match(o.Match, ""); err != nil || o.Match == "" {
+				add("%s.match %q is not a valid pattern", oprefix, o.Match)
+				continue
+			}
+			s := h.Settings.clone()
+			if err := decodeStrict(o.Settings, &s); err != nil {
+				add("%s.settings: %v", oprefix, err)
+				continue
+			}
+			for _, msg := range s.problems() {
+				add("%s.settings.%s", oprefix, msg)
+			}
+		}
+		names := make(map[string]bool)
+		for j, g := range h.Groups {
+			gprefix := fmt.Sprintf("%s.groups[%d]", prefix, j)
+			if g.Name == "" {
+				add("%s.name is empty", gprefix)
+			} else if names[g.Name] {
+				add("%s.name %q is used twice", gprefix, g.Name)
+			}
+			names[g.Name] = true
+			if len(g.Charts) < 2 {
+				add("%s.charts must list at least 2 charts, got %d", gprefix, len(g.Charts))
+			}
+			if !g.Join.Align.Valid() {
+				add("%s.join.align must be inner or outer, got %q", gprefix, g.Join.Align)
+			}
+			if !g.Join.Fill.Valid() {
+				add("%s.join.fill must be one of drop, ffill, zero or linear, got %q", gprefix, g.Join.Fill)
+			}
+			if g.Join.Resolution < 0 {
+				add("%s.join.resolution must be >= 0, got %d", gprefix, g.Join.Resolution)
+			}
+			s := h.Settings.clone()
+			if len(g.Settings) > 0 {
+				if err := decodeStrict(g.Settings, &s); err != nil {
+					add("%s.settings: %v", gprefix, err)
+					continue
+				}
+			}
+			for _, msg := range s.problems() {
+				add("%s.settings.%s", gprefix, msg)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func (s Settings) problems() []string {
+	var problems []string
+	if s.TrainAfter == 0 {
+		problems = append(problems, "trainAfter must be set")
+	}
+	if s.TrainAfter > 0 && s.TrainBefore > 0 && s.TrainAfter >= s.TrainBefore {
+		problems = append(problems, fmt.Sprintf("trainAfter must be before trainBefore, got %d and %d", s.TrainAfter, s.TrainBefore))
+	}
+	if s.TrainPoints < 0 {
+		problems = append(problems, fmt.Sprintf("trainPoints must be >= 0, got %d", s.TrainPoints))
+	}
+	span, fixed := s.trainSpan()
+	if s.TrainPoints > 0 && !fixed {
+		problems = append(problems, fmt.Sprintf("trainPoints needs a window of fixed length, set trainBefore after %d as trainAfter is absolute", s.TrainAfter))
+	}
+	if s.Model.Type == anomaly.SeasonalKind && fixed {
+		for _, season := range s.Model.Seasons {
+			if span < 2*seconds(season) {
+				problems = append(problems, fmt.Sprintf("seasonal models need at least two of each season to train on, got %ds for a %v season", span, time.Duration(season)))
+			}
+		}
+	}
+	if s.Lags < 0 {
+		problems = append(problems, fmt.Sprintf("lags must be >= 0, got %d", s.Lags))
+	}
+	if s.Diffs < 0 {
+		problems = append(problems, fmt.Sprintf("diffs must be >= 0, got %d", s.Diffs))
+	}
+	if s.Smoothing < 0 {
+		problems = append(problems, fmt.Sprintf("smoothing must be >= 0, got %d", s.Smoothing))
+	}
+	if !s.SmoothingMethod.Valid() {
+		problems = append(problems, fmt.Sprintf("smoothingMethod must be one of mean, ewma or median, got %q", s.SmoothingMethod))
+	}
+	for _, msg := range s.Model.problems() {
+		problems = append(problems, "model."+msg)
+	}
+	return problems
+}
+
+// clone copies s so decoding an override into the copy can't write into
+// the slices it shares with s, json reuses a slice's array if it fits
+func (s Settings) clone() Settings {
+	s.Model.Seasons = append([]Duration(nil), s.Model.Seasons...)
+	return s
+}
+
+// Length of the training window in seconds, and false if it depends on
+// when it is asked for, as with an absolute trainAfter up to now. A relative
+// trainAfter counts back from trainBefore.
+func (s Settings) trainSpan() (int64, bool) {
+	switch {
+	case s.TrainAfter < 0 && s.TrainBefore > 0:
+		return -s.TrainAfter, true
+	case s.TrainAfter > 0 && s.TrainBefore <= 0:
+		return 0, false
+	}
+	return s.TrainBefore - s.TrainAfter, true
+}
+
+// TrainStep is how many seconds each training point covers, 1 unless
+// TrainPoints has netdata average the window down
+func (s Settings) TrainStep() int64 {
+	span, fixed := s.trainSpan()
+	if s.TrainPoints <= 0 || !fixed {
+		return 1
+	}
+	step := (span + int64(s.TrainPoints) - 1) / int64(s.TrainPoints)
+	if step < 1 {
+		return 1
+	}
+	return step
+}
+
+// Pipeline returns the feature pipeline for these settings. Per dimension
+// models get the raw dimensions, as lagged or smoothed copies of a column
+// would only count it again.
+func (s Settings) Pipeline() *features.Pipeline {
+	if s.Model.PerDimension() {
+		return features.NewPipeline()
+	}
+	return features.Standard(s.Lags, s.Diffs, s.Smoothing, s.SmoothingMethod)
+}
+
+// SettingsFor returns the settings for chart, with any matching overrides applied
+func (h Host) SettingsFor(chart string) Settings {
+	s := h.Settings.clone()
+	for _, o := range h.Overrides {
+		if ok, _ := path.Match(o.Match, chart); ok {
+			// Already checked by Validate
+			_ = json.Unmarshal(o.Settings, &s)
+		}
+	}
+	return s
+}
+
+// SettingsForGroup returns the settings for g, the host's with the group's
+// changes applied
+func (h Host) SettingsForGroup(g Group) Settings {
+	s := h.Settings.clone()
+	if len(g.Settings) > 0 {
+		// Already checked by Validate
+		_ = json.Unmarshal(g.Settings, &s)
+	}
+	return s
+}
+
+// RetryPolicy returns the host's retry policy
+func (h Host) RetryPolicy() netdata.RetryPolicy {
+	if h.Retry == nil {
+		return netdata.DefaultRetryPolicy
+	}
+	p := netdata.RetryPolicy{
+		MaxAttempts: h.Retry.MaxAttempts,
+		BaseDelay:   time.Duration(h.Retry.BaseDelay),
+		MaxDelay:    time.Duration(h.Retry.MaxDelay),
+		Jitter:      h.Retry.Jitter,
+		RetryStatus: h.Retry.RetryStatus,
+	}
+	if p.RetryStatus == nil {
+		p.RetryStatus = netdata.DefaultRetryPolicy.RetryStatus
+	}
+	return p
+}
+
+// Client makes a netdata client for host using the config's request
+// timeout and the host's retry policy
+func (c *Config) Client(h Host) *netdata.Client {
+	client := netdata.NewClient(h.Host)
+	client.Timeout = time.Duration(c.RequestTimeout)
+	retry := h.RetryPolicy()
+	client.Retry = &retry
+	return client
+}
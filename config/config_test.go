@@ -0,0 +1,135 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverrideLeavesHostSettings(t *testing.T) {
+	b := []byte(`{"hosts": [{
+		"host": "example",
+		"trainAfter": -1209600,
+		"model": {"type": "seasonal", "seasons": ["12h"]},
+		"overrides": [{"match": "system.net", "settings": {"model": {"seasons": ["168h"]}}}],
+		"groups": [{"name": "g", "charts": ["system.cpu", "system.net"], "settings": {"model": {"seasons": ["1h"]}}}]
+	}]}`)
+	c, err := Parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.Hosts[0]
+
+	check := func(what string, got []Duration, want time.Duration) {
+		t.Helper()
+		if len(got) != 1 || time.Duration(got[0]) != want {
+			t.Errorf("%s seasons = %v, want [%v]", what, got, want)
+		}
+	}
+	check("override", h.SettingsFor("system.net").Model.Seasons, 168*time.Hour)
+	check("group", h.SettingsForGroup(h.Groups[0]).Model.Seasons, time.Hour)
+	check("host", h.Settings.Model.Seasons, 12*time.Hour)
+	check("other chart", h.SettingsFor("system.cpu").Model.Seasons, 12*time.Hour)
+	check("defaults", DefaultSettings.Model.Seasons, 24*time.Hour)
+}
+
+func TestTrainStep(t *testing.T) {
+	tests := []struct {
+		after, before int64
+		points        int
+		want          int64
+	}{
+		{-100, 0, 0, 1},
+		{-604800, 0, 10080, 60},
+		{-3600, 1000, 60, 60},
+		{1000, 4600, 60, 60},
+		{-10, 0, 100, 1},
+		{1000, 0, 60, 1},
+	}
+	for _, tt := range tests {
+		s := Settings{TrainAfter: tt.after, TrainBefore: tt.before, TrainPoints: tt.points}
+		if got := s.TrainStep(); got != tt.want {
+			t.Errorf("TrainStep(%d, %d, %d) = %d, want %d", tt.after, tt.before, tt.points, got, tt.want)
+		}
+	}
+}
+
+func TestModelParams(t *testing.T) {
+	m := DefaultSettings.Model
+	m.Type = "iforest"
+	params := string(m.Params())
+
+	// Settings the forest doesn't use leave its params alone
+	other := m
+	other.K, other.Threshold, other.Seasons = 5, 9, nil
+	if got := string(other.Params()); got != params {
+		t.Errorf("params changed from %s to %s", params, got)
+	}
+
+	other.NTrees++
+	if got := string(other.Params()); got == params {
+		t.Errorf("params stayed %s with more trees", got)
+	}
+	other = m
+	other.Type = "kmeans"
+	if got := string(other.Params()); got == params {
+		t.Errorf("kmeans has the forest's params %s", got)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tests := []struct {
+		name, json, want string
+	}{
+		{"zero step timeout", `{"stepTimeout": "0s", "hosts": [{"host": "h"}]}`, "stepTimeout must be > 0"},
+		{"negative request timeout", `{"requestTimeout": "-1s", "hosts": [{"host": "h"}]}`, "requestTimeout must be > 0"},
+		{"zero train timeout", `{"trainTimeout": "0s", "hosts": [{"host": "h"}]}`, "trainTimeout must be > 0"},
+		{"negative step interval", `{"stepInterval": "-1s", "hosts": [{"host": "h"}]}`, "stepInterval must be >= 0"},
+		{"jitter over 1", `{"hosts": [{"host": "h", "retry": {"maxAttempts": 3, "jitter": 1.5}}]}`, "retry.jitter must be from 0 to 1"},
+		{"negative jitter", `{"hosts": [{"host": "h", "retry": {"maxAttempts": 3, "jitter": -0.1}}]}`, "retry.jitter must be from 0 to 1"},
+		{"negative base delay", `{"hosts": [{"host": "h", "retry": {"maxAttempts": 3, "baseDelay": "-1s"}}]}`, "retry.baseDelay must be >= 0"},
+		{"negative max delay", `{"hosts": [{"host": "h", "retry": {"maxAttempts": 3, "maxDelay": "-1s"}}]}`, "retry.maxDelay must be >= 0"},
+		{"bad retry status", `{"hosts": [{"host": "h", "retry": {"maxAttempts": 3, "retryStatus": [5030]}}]}`, "not an http status"},
+		{"points up to now", `{"hosts": [{"host": "h", "trainAfter": 1600000000, "trainPoints": 100}]}`, "trainPoints needs a window of fixed length"},
+		{"one week of a weekly season", `{"hosts": [{"host": "h", "trainAfter": -604800, "model": {"type": "seasonal", "seasons": ["168h"]}}]}`, "at least two of each season"},
+	}
+	for _, tt := range tests {
+		_, err := Parse([]byte(tt.json))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got %v, want an error saying %q", tt.name, err, tt.want)
+		}
+	}
+
+	if _, err := Parse([]byte(`{"hosts": [{"host": "h", "retry": {"maxAttempts": 3, "baseDelay": "100ms", "jitter": 0.5}}]}`)); err != nil {
+		t.Errorf("valid config: %v", err)
+	}
+}
+
+func TestExamples(t *testing.T) {
+	for _, name := range []string{"example.json", "replay.json"} {
+		if _, err := Load(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// The replay example's data is in the repo, its dir is relative to the
+	// repo root where the scripts run
+	c, err := Load("replay.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := *c.Replay
+	r.Dir = filepath.Join("..", r.Dir)
+	replay, err := r.Source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	charts, err := replay.DiscoverCharts(c.Hosts[0].Host, c.Hosts[0].Filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charts) == 0 {
+		t.Error("replay example has no charts to model")
+	}
+}
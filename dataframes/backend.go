@@ -0,0 +1,78 @@
+package dataframes
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+// Backend merges charts fetched as csv on time with a particular dataframe
+// library. They all take the same bodies and give back the same frame, so
+// they can be swapped and timed against each other.
+type Backend interface {
+	Name() string
+
+	// Merge reads each chart's csv body, as got with FetchCSV, and outer
+	// joins them on time, oldest first. Columns are named "chart|dimension"
+	// and values missing from a chart are NaN, features.FillMissing can deal
+	// with them.
+	Merge(ctx context.Context, charts []string, bodies [][]byte) (features.Frame, error)
+}
+
+// The backends by name
+var backends = map[string]Backend{
+	"gota":         Gota{},
+	"dataframe-go": DataframeGo{},
+}
+
+// BackendFor returns the backend called name, "gota" or "dataframe-go"
+func BackendFor(name string) (Backend, error) {
+	b, ok := backends[name]
+	if !ok {
+		return nil, fmt.Errorf("dataframes: unknown backend %q", name)
+	}
+	return b, nil
+}
+
+// BackendNames lists the backends
+func BackendNames() []string {
+	var names []string
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FetchCSV gets each request's chart from client as csv with unix
+// timestamps in the time column, for a Backend to merge. The fetches run on
+// pool so they share its limits. The bodies come back in the same order as
+// reqs.
+func FetchCSV(ctx context.Context, pool *netdata.Pool, client *netdata.Client, reqs []netdata.DataRequest) ([][]byte, error) {
+	jobs := make([]netdata.Job, len(reqs))
+	for i, req := range reqs {
+		req.Format = "csv"
+		req.Options = append(append([]string(nil), req.Options...), "seconds")
+		jobs[i] = netdata.Job{Client: client, Request: req}
+	}
+	bodies := make([][]byte, len(reqs))
+	for i, res := range pool.Fetch(ctx, jobs) {
+		if res.Err != nil {
+			return nil, fmt.Errorf("dataframes: %v: %w", reqs[i].Chart, res.Err)
+		}
+		bodies[i] = res.Body
+	}
+	return bodies, nil
+}
+
+// Make a frame from a merged time column and named value columns
+func toFrame(times []float64, names []string, cols [][]float64) features.Frame {
+	f := features.FromColumns(names, cols)
+	for r, t := range times {
+		f.Times[r] = int64(t)
+	}
+	return f
+}
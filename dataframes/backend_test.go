@@ -0,0 +1,135 @@
+package dataframes
+
+import (
+	"context"
+	"math"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andrewm4894/learn-go/fakenetdata"
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+// Two charts as netdata sends them with the seconds option, newest first.
+// They overlap at 101 and 102, cpu has no row at 103, load none at 100 and
+// a null at 101.
+var (
+	testCharts = []string{"system.cpu", "system.load"}
+	testBodies = [][]byte{
+		[]byte("time,user,system\n103,1.5,2\n102,1,2.25\n101,0.5,2.5\n100,0,3\n"),
+		[]byte("time,load1\n104,4\n103,3\n102,2\n101,null\n"),
+	}
+)
+
+func TestBackendsAgree(t *testing.T) {
+	nan := math.NaN()
+	want := features.Frame{
+		Names: []string{"system.cpu|user", "system.cpu|system", "system.load|load1"},
+		Times: []int64{100, 101, 102, 103, 104},
+		Rows: [][]float64{
+			{0, 3, nan},
+			{0.5, 2.5, nan},
+			{1, 2.25, 2},
+			{1.5, 2, 3},
+			{nan, nan, 4},
+		},
+	}
+	for _, name := range BackendNames() {
+		t.Run(name, func(t *testing.T) {
+			b, err := BackendFor(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := b.Merge(context.Background(), testCharts, testBodies)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalFrames(got, want) {
+				t.Errorf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestBackendErrors(t *testing.T) {
+	for _, name := range BackendNames() {
+		b, _ := BackendFor(name)
+		if _, err := b.Merge(context.Background(), testCharts, testBodies[:1]); err == nil {
+			t.Errorf("%s: more charts than bodies: got no error", name)
+		}
+	}
+	if _, err := BackendFor("pandas"); err == nil {
+		t.Error("unknown backend: got no error")
+	}
+}
+
+func TestFetchCSV(t *testing.T) {
+	s := fakenetdata.New(1)
+	s.Now = func() time.Time { return time.Unix(1600000000, 0) }
+	ts := httptest.NewServer(s)
+	defer ts.Close()
+	client := netdata.NewClient(ts.URL)
+	pool := netdata.NewPool(netdata.PoolOptions{Workers: 2, PerHost: 2})
+	defer pool.Close()
+
+	reqs := []netdata.DataRequest{
+		{Chart: "system.load", After: -59},
+		{Chart: "system.cpu", After: -59},
+	}
+	bodies, err := FetchCSV(context.Background(), pool, client, reqs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both backends read the bodies back to the same frame, in the order
+	// asked for, the csv matching the json api
+	charts := []string{"system.load", "system.cpu"}
+	var frames []features.Frame
+	for _, name := range BackendNames() {
+		b, _ := BackendFor(name)
+		f, err := b.Merge(context.Background(), charts, bodies)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		frames = append(frames, f)
+	}
+	if !equalFrames(frames[0], frames[1]) {
+		t.Errorf("backends disagree on fetched csv:\n%+v\n%+v", frames[0], frames[1])
+	}
+	resp, err := client.Data(context.Background(), reqs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	newest := resp.Data[0]
+	last := frames[0].Rows[len(frames[0].Rows)-1]
+	if frames[0].Names[0] != "system.load|"+resp.Labels[1] || frames[0].Times[len(frames[0].Times)-1] != int64(newest[0]) || last[0] != newest[1] {
+		t.Errorf("newest load1 is %v at %v, want %v at %v", last[0], frames[0].Times[len(frames[0].Times)-1], newest[1], newest[0])
+	}
+
+	reqs = append(reqs, netdata.DataRequest{Chart: "nope"})
+	if _, err := FetchCSV(context.Background(), pool, client, reqs); err == nil {
+		t.Error("unknown chart: got no error")
+	}
+}
+
+// Frames are equal with NaN equal to itself
+func equalFrames(a, b features.Frame) bool {
+	if !reflect.DeepEqual(a.Names, b.Names) || !reflect.DeepEqual(a.Times, b.Times) || len(a.Rows) != len(b.Rows) {
+		return false
+	}
+	for i := range a.Rows {
+		if len(a.Rows[i]) != len(b.Rows[i]) {
+			return false
+		}
+		for j, v := range a.Rows[i] {
+			w := b.Rows[i][j]
+			if v != w && !(math.IsNaN(v) && math.IsNaN(w)) {
+				return false
+			}
+		}
+	}
+	return true
+}
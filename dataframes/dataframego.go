@@ -0,0 +1,144 @@
+package dataframes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/features"
+	dataframe "github.com/rocketlaunchr/dataframe-go"
+	"github.com/rocketlaunchr/dataframe-go/imports"
+	"github.com/sjwhitworth/golearn/base"
+)
+
+// FromDataframeGo makes golearn instances from a rocketlaunchr dataframe.
+// Float64 and int64 series become float attributes, others are left out.
+func FromDataframeGo(df *dataframe.DataFrame, opts Options) (base.FixedDataGrid, error) {
+	names, cols := dataframeGoColumns(df, opts)
+	return toInstances(names, cols, opts.NA)
+}
+
+// Numeric columns of df, with NaN where values are missing
+func dataframeGoColumns(df *dataframe.DataFrame, opts Options) ([]string, [][]float64) {
+	var names []string
+	var cols [][]float64
+	for _, s := range df.Series {
+		name := s.Name()
+		if opts.excluded(name) {
+			continue
+		}
+		switch s.Type() {
+		case "float64", "int64":
+		default:
+			continue
+		}
+		names = append(names, name)
+		cols = append(cols, seriesFloats(s))
+	}
+	return names, cols
+}
+
+// Values of a numeric series, missing values come back as nil and become NaN
+func seriesFloats(s dataframe.Series) []float64 {
+	col := make([]float64, s.NRows())
+	for r := range col {
+		switch v := s.Value(r).(type) {
+		case float64:
+			col[r] = v
+		case int64:
+			col[r] = float64(v)
+		default:
+			col[r] = math.NaN()
+		}
+	}
+	return col
+}
+
+// DataframeGo is the Backend using rocketlaunchr dataframes
+type DataframeGo struct{}
+
+// Name implements Backend
+func (DataframeGo) Name() string {
+	return "dataframe-go"
+}
+
+// Merge implements Backend
+func (DataframeGo) Merge(ctx context.Context, charts []string, bodies [][]byte) (features.Frame, error) {
+	if len(charts) != len(bodies) {
+		return features.Frame{}, fmt.Errorf("dataframes: %d charts for %d bodies", len(charts), len(bodies))
+	}
+	// Netdata writes missing values as null
+	na := "null"
+	dfs := make([]*dataframe.DataFrame, len(bodies))
+	for i, body := range bodies {
+		var err error
+		dfs[i], err = imports.LoadFromCSV(ctx, bytes.NewReader(body), imports.CSVLoadOptions{InferDataTypes: true, NilValue: &na})
+		if err != nil {
+			return features.Frame{}, fmt.Errorf("dataframes: %v: %w", charts[i], err)
+		}
+	}
+	df, err := MergeDataframeGo(charts, dfs)
+	if err != nil {
+		return features.Frame{}, err
+	}
+	names, cols := dataframeGoColumns(df, Options{})
+	return toFrame(seriesFloats(df.Series[0]), names, cols), nil
+}
+
+// MergeDataframeGo outer joins rocketlaunchr dataframes on their time
+// columns, which the library can't do itself. The result has a float64 time
+// column, sorted, then each frame's columns named "chart|dimension" after
+// the matching chart.
+func MergeDataframeGo(charts []string, dfs []*dataframe.DataFrame) (*dataframe.DataFrame, error) {
+	type column struct {
+		name   string
+		byTime map[float64]float64
+	}
+	var columns []column
+	seen := make(map[float64]bool)
+	for i, df := range dfs {
+		t, err := df.NameToColumn("time")
+		if err != nil {
+			return nil, fmt.Errorf("dataframes: %v: %w", charts[i], err)
+		}
+		times := seriesFloats(df.Series[t])
+		for _, ts := range times {
+			seen[ts] = true
+		}
+		names, cols := dataframeGoColumns(df, Options{})
+		for j, name := range names {
+			c := column{name: charts[i] + "|" + name, byTime: make(map[float64]float64)}
+			for r, ts := range times {
+				c.byTime[ts] = cols[j][r]
+			}
+			columns = append(columns, c)
+		}
+	}
+
+	var times []float64
+	for ts := range seen {
+		if !math.IsNaN(ts) {
+			times = append(times, ts)
+		}
+	}
+	sort.Float64s(times)
+
+	timeVals := make([]interface{}, len(times))
+	for r, ts := range times {
+		timeVals[r] = ts
+	}
+	series := []dataframe.Series{dataframe.NewSeriesFloat64("time", nil, timeVals...)}
+	for _, c := range columns {
+		vals := make([]interface{}, len(times))
+		for r, ts := range times {
+			// nil is missing to dataframe-go
+			if v, ok := c.byTime[ts]; ok && !math.IsNaN(v) {
+				vals[r] = v
+			}
+		}
+		series = append(series, dataframe.NewSeriesFloat64(c.name, nil, vals...))
+	}
+	return dataframe.NewDataFrame(series...), nil
+}
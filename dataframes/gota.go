@@ -0,0 +1,97 @@
+package dataframes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/go-gota/gota/dataframe"
+	"github.com/go-gota/gota/series"
+	"github.com/sjwhitworth/golearn/base"
+)
+
+// FromGota makes golearn instances from a gota dataframe. Float, int and
+// bool columns become float attributes, string columns are left out.
+func FromGota(df dataframe.DataFrame, opts Options) (base.FixedDataGrid, error) {
+	if df.Err != nil {
+		return nil, df.Err
+	}
+	names, cols := gotaColumns(df, opts)
+	return toInstances(names, cols, opts.NA)
+}
+
+// Numeric columns of df, with NaN where values are missing
+func gotaColumns(df dataframe.DataFrame, opts Options) ([]string, [][]float64) {
+	var names []string
+	var cols [][]float64
+	for _, name := range df.Names() {
+		if opts.excluded(name) {
+			continue
+		}
+		s := df.Col(name)
+		switch s.Type() {
+		case series.Float, series.Int, series.Bool:
+		default:
+			continue
+		}
+		// Float gives NaN for missing values
+		names = append(names, name)
+		cols = append(cols, s.Float())
+	}
+	return names, cols
+}
+
+// Netdata writes missing values as null, the rest are gota's defaults
+var nullValues = []string{"null", "NA", "NaN", "<nil>"}
+
+// Gota is the Backend using gota dataframes
+type Gota struct{}
+
+// Name implements Backend
+func (Gota) Name() string {
+	return "gota"
+}
+
+// Merge implements Backend
+func (Gota) Merge(ctx context.Context, charts []string, bodies [][]byte) (features.Frame, error) {
+	if len(charts) != len(bodies) {
+		return features.Frame{}, fmt.Errorf("dataframes: %d charts for %d bodies", len(charts), len(bodies))
+	}
+	dfs := make([]dataframe.DataFrame, len(bodies))
+	for i, body := range bodies {
+		dfs[i] = dataframe.ReadCSV(bytes.NewReader(body), dataframe.NaNValues(nullValues))
+		if dfs[i].Err != nil {
+			return features.Frame{}, fmt.Errorf("dataframes: %v: %w", charts[i], dfs[i].Err)
+		}
+		dfs[i] = prefixGota(dfs[i], charts[i])
+	}
+	df := MergeGota(dfs)
+	if df.Err != nil {
+		return features.Frame{}, df.Err
+	}
+	names, cols := gotaColumns(df, Options{})
+	return toFrame(df.Col("time").Float(), names, cols), nil
+}
+
+// Name every column but time "chart|dimension"
+func prefixGota(df dataframe.DataFrame, chart string) dataframe.DataFrame {
+	for _, name := range df.Names() {
+		if name != "time" {
+			df = df.Rename(chart+"|"+name, name)
+		}
+	}
+	return df
+}
+
+// MergeGota outer joins gota dataframes on their time columns, sorted by time
+func MergeGota(dfs []dataframe.DataFrame) dataframe.DataFrame {
+	if len(dfs) == 0 {
+		return dataframe.DataFrame{Err: fmt.Errorf("dataframes: nothing to merge")}
+	}
+	df := dfs[0]
+	for _, other := range dfs[1:] {
+		df = df.OuterJoin(other, "time")
+	}
+	return df.Arrange(dataframe.Sort("time"))
+}
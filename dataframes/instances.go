@@ -0,0 +1,105 @@
+// Package dataframes moves netdata data between dataframe libraries and
+// golearn
+package dataframes
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/sjwhitworth/golearn/base"
+)
+
+// NA says what to do with rows that have missing values
+type NA string
+
+// Ways to handle missing values
+const (
+	// DropNA drops rows with any missing values
+	DropNA NA = "drop"
+
+	// ZeroNA fills missing values with 0
+	ZeroNA NA = "zero"
+
+	// ErrorNA makes missing values an error
+	ErrorNA NA = "error"
+)
+
+// Options for turning a dataframe into golearn instances
+type Options struct {
+	// Exclude names columns to leave out, nil means just "time"
+	Exclude []string
+
+	// NA defaults to DropNA
+	NA NA
+}
+
+func (o Options) excluded(name string) bool {
+	exclude := o.Exclude
+	if exclude == nil {
+		exclude = []string{"time"}
+	}
+	for _, e := range exclude {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Make golearn instances from named float columns, NaN marks a missing value
+func toInstances(names []string, cols [][]float64, na NA) (base.FixedDataGrid, error) {
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("dataframes: no numeric columns")
+	}
+
+	// Rows to keep once missing values are dealt with
+	var keep []int
+	for r := range cols[0] {
+		missing := false
+		for j, col := range cols {
+			if !math.IsNaN(col[r]) {
+				continue
+			}
+			switch na {
+			case ZeroNA:
+				col[r] = 0
+			case ErrorNA:
+				return nil, fmt.Errorf("dataframes: missing value in %v at row %d", names[j], r)
+			case DropNA, "":
+				missing = true
+			default:
+				return nil, fmt.Errorf("dataframes: unknown NA handling %q", na)
+			}
+		}
+		if !missing {
+			keep = append(keep, r)
+		}
+	}
+	if len(keep) == 0 {
+		return nil, fmt.Errorf("dataframes: no rows left without missing values")
+	}
+
+	inst := base.NewDenseInstances()
+	specs := make([]base.AttributeSpec, len(names))
+	attrs := make([]base.Attribute, len(names))
+	for j, name := range names {
+		attr := base.NewFloatAttribute(name)
+		attrs[j] = attr
+		specs[j] = inst.AddAttribute(attr)
+	}
+	if err := inst.Extend(len(keep)); err != nil {
+		return nil, err
+	}
+	for i, r := range keep {
+		for j, col := range cols {
+			inst.Set(specs[j], i, base.PackFloatToBytes(col[r]))
+		}
+	}
+
+	// Must set a class attribute in golearn, any feature will do as per
+	// https://github.com/sjwhitworth/golearn/issues/260#issuecomment-756086922
+	if err := inst.AddClassAttribute(attrs[0]); err != nil {
+		return nil, err
+	}
+	return inst, nil
+}
@@ -0,0 +1,135 @@
+package dataframes
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-gota/gota/dataframe"
+	rl "github.com/rocketlaunchr/dataframe-go"
+	"github.com/sjwhitworth/golearn/base"
+)
+
+// Names and rows of golearn instances, read back as floats
+func instanceRows(t *testing.T, inst base.FixedDataGrid) ([]string, [][]float64) {
+	t.Helper()
+	attrs := inst.AllAttributes()
+	names := make([]string, len(attrs))
+	specs := make([]base.AttributeSpec, len(attrs))
+	for j, attr := range attrs {
+		names[j] = attr.GetName()
+		spec, err := inst.GetAttribute(attr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		specs[j] = spec
+	}
+	_, n := inst.Size()
+	rows := make([][]float64, n)
+	for i := range rows {
+		for _, spec := range specs {
+			rows[i] = append(rows[i], base.UnpackBytesToFloat(inst.Get(spec, i)))
+		}
+	}
+	return names, rows
+}
+
+func TestToInstances(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		na      NA
+		want    [][]float64
+		wantErr bool
+	}{
+		{"", [][]float64{{1, 4}, {3, 6}}, false},
+		{DropNA, [][]float64{{1, 4}, {3, 6}}, false},
+		{ZeroNA, [][]float64{{1, 4}, {0, 5}, {3, 6}}, false},
+		{ErrorNA, nil, true},
+		{"mean", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.na), func(t *testing.T) {
+			cols := [][]float64{{1, nan, 3}, {4, 5, 6}}
+			inst, err := toInstances([]string{"a", "b"}, cols, tt.na)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names, rows := instanceRows(t, inst)
+			if !reflect.DeepEqual(names, []string{"a", "b"}) || !reflect.DeepEqual(rows, tt.want) {
+				t.Errorf("got %v %v, want [a b] %v", names, rows, tt.want)
+			}
+			if class := inst.AllClassAttributes(); len(class) != 1 || class[0].GetName() != "a" {
+				t.Errorf("class attributes %v, want [a]", class)
+			}
+		})
+	}
+
+	if _, err := toInstances(nil, nil, DropNA); err == nil {
+		t.Error("no columns: got no error")
+	}
+	if _, err := toInstances([]string{"a"}, [][]float64{{nan, nan}}, DropNA); err == nil {
+		t.Error("every row missing a value: got no error")
+	}
+}
+
+func TestExcluded(t *testing.T) {
+	if !(Options{}).excluded("time") || (Options{}).excluded("user") {
+		t.Error("no Exclude should leave out just time")
+	}
+	opts := Options{Exclude: []string{"user"}}
+	if opts.excluded("time") || !opts.excluded("user") {
+		t.Error("Exclude [user] should leave out just user")
+	}
+	if (Options{Exclude: []string{}}).excluded("time") {
+		t.Error("an empty Exclude should keep time")
+	}
+}
+
+func TestFromGota(t *testing.T) {
+	df := dataframe.ReadCSV(strings.NewReader("time,host,user,up\n100,a,1.5,true\n101,b,NA,false\n102,c,2,true\n"))
+	inst, err := FromGota(df, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// time is excluded, host is a string so left out, the row with NA dropped
+	names, rows := instanceRows(t, inst)
+	if want := []string{"user", "up"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v, want %v", names, want)
+	}
+	if want := [][]float64{{1.5, 1}, {2, 1}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows %v, want %v", rows, want)
+	}
+
+	errTest := errors.New("broken")
+	if _, err := FromGota(dataframe.DataFrame{Err: errTest}, Options{}); err != errTest {
+		t.Errorf("dataframe with an error gave %v, want %v", err, errTest)
+	}
+}
+
+func TestFromDataframeGo(t *testing.T) {
+	df := rl.NewDataFrame(
+		rl.NewSeriesFloat64("time", nil, 100.0, 101.0, 102.0),
+		rl.NewSeriesString("host", nil, "a", "b", "c"),
+		rl.NewSeriesFloat64("user", nil, 1.5, nil, 2.0),
+		rl.NewSeriesInt64("count", nil, 1, 2, 3),
+	)
+	inst, err := FromDataframeGo(df, Options{NA: ZeroNA})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, rows := instanceRows(t, inst)
+	if want := []string{"user", "count"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("names %v, want %v", names, want)
+	}
+	if want := [][]float64{{1.5, 1}, {0, 2}, {2, 3}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows %v, want %v", rows, want)
+	}
+}
@@ -0,0 +1,95 @@
+package evaluate
+
+// Range is a run of rows, Start and End are row indexes and inclusive
+type Range struct {
+	Start, End int
+}
+
+// Ranges finds the runs of true in flags
+func Ranges(flags []bool) []Range {
+	var ranges []Range
+	for i := 0; i < len(flags); i++ {
+		if !flags[i] {
+			continue
+		}
+		r := Range{Start: i}
+		for i+1 < len(flags) && flags[i+1] {
+			i++
+		}
+		r.End = i
+		ranges = append(ranges, r)
+	}
+	return ranges
+}
+
+// EventReport scores detections by anomalous event rather than by row, as a
+// detector that flags part of a long anomaly has still found it
+type EventReport struct {
+	Events   int
+	Detected int
+
+	// Recall is the fraction of events with at least one row flagged
+	Recall float64
+
+	// RangeRecall is the fraction of each event's rows flagged, averaged
+	// over events
+	RangeRecall float64
+
+	// MeanDelay is the average time in seconds from the start of each
+	// detected event to its first flagged row
+	MeanDelay float64
+
+	// FalseAlarms counts runs of flagged rows that touch no event
+	FalseAlarms int
+}
+
+// Events scores the rows flagged at threshold against labels, times are the
+// rows' timestamps for working out delays
+func Events(times []int64, scores []float64, labels []bool, threshold float64) (EventReport, error) {
+	if err := check(scores, labels); err != nil {
+		return EventReport{}, err
+	}
+	flagged := make([]bool, len(scores))
+	for i, s := range scores {
+		flagged[i] = s >= threshold
+	}
+
+	var rep EventReport
+	var delay float64
+	for _, e := range Ranges(labels) {
+		rep.Events++
+		hits := 0
+		first := -1
+		for i := e.Start; i <= e.End; i++ {
+			if flagged[i] {
+				hits++
+				if first < 0 {
+					first = i
+				}
+			}
+		}
+		rep.RangeRecall += float64(hits) / float64(e.End-e.Start+1)
+		if first >= 0 {
+			rep.Detected++
+			delay += float64(times[first] - times[e.Start])
+		}
+	}
+	if rep.Events > 0 {
+		rep.Recall = float64(rep.Detected) / float64(rep.Events)
+		rep.RangeRecall /= float64(rep.Events)
+	}
+	if rep.Detected > 0 {
+		rep.MeanDelay = delay / float64(rep.Detected)
+	}
+
+	for _, r := range Ranges(flagged) {
+		touches := false
+		for i := r.Start; i <= r.End && !touches; i++ {
+			touches = labels[i]
+		}
+		if !touches {
+			rep.FalseAlarms++
+		}
+	}
+	return rep, nil
+}
@@ -0,0 +1,95 @@
+package evaluate
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRanges(t *testing.T) {
+	tests := []struct {
+		flags []bool
+		want  []Range
+	}{
+		{nil, nil},
+		{[]bool{false, false}, nil},
+		{[]bool{true}, []Range{{0, 0}}},
+		{[]bool{true, true, false, true}, []Range{{0, 1}, {3, 3}}},
+		{[]bool{false, true, true, true}, []Range{{1, 3}}},
+	}
+	for _, tt := range tests {
+		if got := Ranges(tt.flags); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Ranges(%v) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	// Rows every 10 seconds
+	times := make([]int64, 10)
+	for i := range times {
+		times[i] = int64(10 * i)
+	}
+	tests := []struct {
+		name    string
+		scores  []float64
+		labels  []bool
+		want    EventReport
+		wantErr bool
+	}{
+		{
+			// Two injected events back to back are one run of labels, so one
+			// event. It is found 2 rows in, the lone event at 8 is missed and
+			// the flags at 0 and 9 touch no event.
+			name:   "overlapping events",
+			scores: []float64{0.9, 0, 0, 0, 0.7, 0.8, 0, 0, 0, 0.6},
+			labels: []bool{false, false, true, true, true, true, true, false, true, false},
+			want:   EventReport{Events: 2, Detected: 1, Recall: 0.5, RangeRecall: 0.2, MeanDelay: 20, FalseAlarms: 2},
+		},
+		{
+			// One run of flags across two events finds both, and touches them
+			// so is no false alarm even where it covers normal rows
+			name:   "one flag run over two events",
+			scores: []float64{0, 1, 1, 1, 1, 1, 0, 0, 0, 0},
+			labels: []bool{false, false, true, false, true, true, false, false, false, false},
+			want:   EventReport{Events: 2, Detected: 2, Recall: 1, RangeRecall: 1, MeanDelay: 0},
+		},
+		{
+			name:   "no events",
+			scores: []float64{0, 1, 0, 0, 0, 0, 1, 1, 0, 0},
+			labels: make([]bool, 10),
+			want:   EventReport{FalseAlarms: 2},
+		},
+		{
+			name:   "all anomalous",
+			scores: []float64{0, 0, 0.5, 0, 0, 0, 0, 0, 0, 0},
+			labels: []bool{true, true, true, true, true, true, true, true, true, true},
+			want:   EventReport{Events: 1, Detected: 1, Recall: 1, RangeRecall: 0.1, MeanDelay: 20},
+		},
+		{
+			name:    "mismatched lengths",
+			scores:  []float64{1},
+			labels:  make([]bool, 10),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Events(times, tt.scores, tt.labels, 0.5)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Events != tt.want.Events || got.Detected != tt.want.Detected || got.FalseAlarms != tt.want.FalseAlarms ||
+				math.Abs(got.Recall-tt.want.Recall) > 1e-9 || math.Abs(got.RangeRecall-tt.want.RangeRecall) > 1e-9 ||
+				math.Abs(got.MeanDelay-tt.want.MeanDelay) > 1e-9 {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
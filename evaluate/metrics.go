@@ -0,0 +1,144 @@
+// Package evaluate scores anomaly detectors against labelled data
+package evaluate
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ROCAUC is the area under the roc curve, the chance a random anomalous row
+// scores higher than a random normal one. Ties count half.
+func ROCAUC(scores []float64, labels []bool) (float64, error) {
+	if err := check(scores, labels); err != nil {
+		return 0, err
+	}
+
+	// Mann-Whitney U from the ranks of the anomalous rows, tied scores share
+	// their average rank
+	order := byScore(scores)
+	var rankSum float64
+	var pos, neg int
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && scores[order[j]] == scores[order[i]] {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for _, k := range order[i:j] {
+			if labels[k] {
+				rankSum += rank
+				pos++
+			} else {
+				neg++
+			}
+		}
+		i = j
+	}
+	if pos == 0 || neg == 0 {
+		return 0, fmt.Errorf("evaluate: roc auc needs both anomalous and normal rows")
+	}
+	return (rankSum - float64(pos*(pos+1))/2) / float64(pos*neg), nil
+}
+
+// PRAUC is the area under the precision recall curve, as average precision:
+// the precision at each anomalous row's score, averaged
+func PRAUC(scores []float64, labels []bool) (float64, error) {
+	if err := check(scores, labels); err != nil {
+		return 0, err
+	}
+
+	// Walk down from the highest score, taking tied scores together
+	order := byScore(scores)
+	var total int
+	for _, l := range labels {
+		if l {
+			total++
+		}
+	}
+	if total == 0 {
+		return 0, fmt.Errorf("evaluate: pr auc needs some anomalous rows")
+	}
+	var ap float64
+	var tp, seen int
+	for i := len(order) - 1; i >= 0; {
+		j := i
+		newTP := 0
+		for j >= 0 && scores[order[j]] == scores[order[i]] {
+			if labels[order[j]] {
+				newTP++
+			}
+			j--
+		}
+		tp += newTP
+		seen += i - j
+		ap += float64(newTP) / float64(total) * float64(tp) / float64(seen)
+		i = j
+	}
+	return ap, nil
+}
+
+// Counts is a confusion matrix
+type Counts struct {
+	TP, FP, FN, TN int
+}
+
+// At counts rows as anomalous if their score is at least threshold
+func At(scores []float64, labels []bool, threshold float64) Counts {
+	var c Counts
+	for i, s := range scores {
+		switch {
+		case s >= threshold && labels[i]:
+			c.TP++
+		case s >= threshold:
+			c.FP++
+		case labels[i]:
+			c.FN++
+		default:
+			c.TN++
+		}
+	}
+	return c
+}
+
+// Precision is TP/(TP+FP), 0 if nothing was flagged
+func (c Counts) Precision() float64 {
+	return ratio(c.TP, c.TP+c.FP)
+}
+
+// Recall is TP/(TP+FN), 0 if there were no anomalies
+func (c Counts) Recall() float64 {
+	return ratio(c.TP, c.TP+c.FN)
+}
+
+// F1 is the harmonic mean of precision and recall
+func (c Counts) F1() float64 {
+	p, r := c.Precision(), c.Recall()
+	if p+r == 0 {
+		return 0
+	}
+	return 2 * p * r / (p + r)
+}
+
+func ratio(a, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func check(scores []float64, labels []bool) error {
+	if len(scores) != len(labels) {
+		return fmt.Errorf("evaluate: %d scores for %d labels", len(scores), len(labels))
+	}
+	return nil
+}
+
+// Indexes of scores from lowest to highest
+func byScore(scores []float64) []int {
+	order := make([]int, len(scores))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] < scores[order[b]] })
+	return order
+}
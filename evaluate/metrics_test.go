@@ -0,0 +1,99 @@
+package evaluate
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAUC(t *testing.T) {
+	tests := []struct {
+		name   string
+		scores []float64
+		labels []bool
+		roc    float64
+		pr     float64
+	}{
+		{"textbook", []float64{0.1, 0.4, 0.35, 0.8}, []bool{false, false, true, true}, 0.75, 5.0 / 6},
+		{"perfect", []float64{0.9, 0.8, 0.1, 0.2}, []bool{true, true, false, false}, 1, 1},
+		{"backwards", []float64{0.1, 0.2, 0.9, 0.8}, []bool{true, true, false, false}, 0, 0.5/3 + 0.25},
+		{"all tied", []float64{0.5, 0.5, 0.5, 0.5}, []bool{true, false, true, false}, 0.5, 0.5},
+		{"tied with a normal row", []float64{1, 1, 0}, []bool{true, false, false}, 0.75, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roc, err := ROCAUC(tt.scores, tt.labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(roc-tt.roc) > 1e-9 {
+				t.Errorf("ROCAUC = %v, want %v", roc, tt.roc)
+			}
+			pr, err := PRAUC(tt.scores, tt.labels)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(pr-tt.pr) > 1e-9 {
+				t.Errorf("PRAUC = %v, want %v", pr, tt.pr)
+			}
+		})
+	}
+}
+
+func TestAUCOneClass(t *testing.T) {
+	scores := []float64{0.2, 0.9, 0.5}
+
+	// All anomalous has no normal rows to rank against, but every row found
+	// is a true one
+	if _, err := ROCAUC(scores, []bool{true, true, true}); err == nil {
+		t.Error("ROCAUC of all anomalous rows: got no error")
+	}
+	if pr, err := PRAUC(scores, []bool{true, true, true}); err != nil || pr != 1 {
+		t.Errorf("PRAUC of all anomalous rows = %v, %v, want 1", pr, err)
+	}
+
+	// All normal has nothing to find
+	if _, err := ROCAUC(scores, []bool{false, false, false}); err == nil {
+		t.Error("ROCAUC of all normal rows: got no error")
+	}
+	if _, err := PRAUC(scores, []bool{false, false, false}); err == nil {
+		t.Error("PRAUC of all normal rows: got no error")
+	}
+
+	for name, fn := range map[string]func([]float64, []bool) (float64, error){"ROCAUC": ROCAUC, "PRAUC": PRAUC} {
+		if _, err := fn(scores, []bool{true, false}); err == nil {
+			t.Errorf("%s of mismatched lengths: got no error", name)
+		}
+		if _, err := fn(nil, nil); err == nil {
+			t.Errorf("%s of no rows: got no error", name)
+		}
+	}
+}
+
+func TestAt(t *testing.T) {
+	scores := []float64{0.9, 0.6, 0.4, 0.7}
+	labels := []bool{true, false, true, false}
+	tests := []struct {
+		threshold float64
+		want      Counts
+		p, r, f1  float64
+	}{
+		// A score equal to the threshold is flagged
+		{0.6, Counts{TP: 1, FP: 2, FN: 1}, 1.0 / 3, 0.5, 0.4},
+		{0.4, Counts{TP: 2, FP: 2}, 0.5, 1, 2.0 / 3},
+		{0.95, Counts{FN: 2, TN: 2}, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		c := At(scores, labels, tt.threshold)
+		if c != tt.want {
+			t.Errorf("At(%v) = %+v, want %+v", tt.threshold, c, tt.want)
+		}
+		if math.Abs(c.Precision()-tt.p) > 1e-9 || math.Abs(c.Recall()-tt.r) > 1e-9 || math.Abs(c.F1()-tt.f1) > 1e-9 {
+			t.Errorf("At(%v) precision, recall, f1 = %v, %v, %v, want %v, %v, %v",
+				tt.threshold, c.Precision(), c.Recall(), c.F1(), tt.p, tt.r, tt.f1)
+		}
+	}
+
+	if c := At(scores, []bool{false, false, false, false}, 0.5); c.Recall() != 0 || c.F1() != 0 {
+		t.Errorf("no anomalies gave recall %v and f1 %v, want 0", c.Recall(), c.F1())
+	}
+}
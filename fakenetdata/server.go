@@ -0,0 +1,308 @@
+// Package fakenetdata is a stand in for a netdata agent, serving made up
+// but repeatable chart data over the same REST api
+package fakenetdata
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+// Dimension is one made up series. Its value at time t is
+//
+//	Base + Amplitude*sin(2*pi*t/Period) + Noise*N(0,1)
+//
+// with the noise the same every time t is asked for.
+type Dimension struct {
+	Name      string
+	Base      float64
+	Amplitude float64
+	Period    float64 // seconds, no seasonality if 0
+	Noise     float64
+}
+
+// Chart is a chart and its dimensions
+type Chart struct {
+	ID         string
+	Family     string
+	Units      string
+	Dimensions []Dimension
+}
+
+// Anomaly changes a chart's data between Start and End, unix seconds
+// inclusive. Shift is added to the value and the noise is multiplied by
+// NoiseScale, if it is set.
+type Anomaly struct {
+	Chart      string
+	Dimension  string // all of them if empty
+	Start, End int64
+	Shift      float64
+	NoiseScale float64
+}
+
+// Server serves /api/v1/data, /api/v1/charts and /api/v1/info for Charts.
+// Use it as an http.Handler, with httptest.NewServer or http.ListenAndServe.
+type Server struct {
+	Hostname  string
+	Seed      int64
+	Charts    []Chart
+	Anomalies []Anomaly
+
+	// History is how many seconds of data the server keeps, like an
+	// agent's retention. Requests are cut to it, and to now, as the real
+	// api does. 0 keeps everything.
+	History int64
+
+	// Now is the server's clock, time.Now if nil
+	Now func() time.Time
+}
+
+// New makes a server with a few system charts like a real agent's, cpu
+// and net have a daily cycle. It keeps four weeks of history.
+func New(seed int64) *Server {
+	day := float64(24 * 60 * 60)
+	return &Server{
+		Hostname: "fake-netdata",
+		Seed:     seed,
+		History:  28 * int64(day),
+		Charts: []Chart{
+			{ID: "system.cpu", Family: "cpu", Units: "percentage", Dimensions: []Dimension{
+				{Name: "user", Base: 20, Amplitude: 10, Period: day, Noise: 2},
+				{Name: "system", Base: 8, Amplitude: 3, Period: day, Noise: 1},
+				{Name: "iowait", Base: 1, Noise: 0.3},
+			}},
+			{ID: "system.load", Family: "load", Units: "load", Dimensions: []Dimension{
+				{Name: "load1", Base: 1.5, Amplitude: 0.5, Period: day, Noise: 0.2},
+				{Name: "load5", Base: 1.4, Amplitude: 0.5, Period: day, Noise: 0.1},
+				{Name: "load15", Base: 1.3, Amplitude: 0.5, Period: day, Noise: 0.05},
+			}},
+			{ID: "system.io", Family: "disk", Units: "KiB/s", Dimensions: []Dimension{
+				{Name: "in", Base: 500, Noise: 100},
+				{Name: "out", Base: -800, Noise: 150},
+			}},
+			{ID: "system.net", Family: "network", Units: "kilobits/s", Dimensions: []Dimension{
+				{Name: "received", Base: 3000, Amplitude: 2000, Period: day, Noise: 300},
+				{Name: "sent", Base: -2000, Amplitude: 1500, Period: day, Noise: 200},
+			}},
+			{ID: "system.uptime", Family: "uptime", Units: "seconds", Dimensions: []Dimension{
+				{Name: "uptime", Base: 86400},
+			}},
+		},
+	}
+}
+
+func (s *Server) now() int64 {
+	if s.Now != nil {
+		return s.Now().Unix()
+	}
+	return time.Now().Unix()
+}
+
+func (s *Server) chart(id string) (Chart, bool) {
+	for _, c := range s.Charts {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Chart{}, false
+}
+
+// Value is dimension dim of chart at unix time t, anomalies included
+func (s *Server) Value(chart Chart, dim Dimension, t int64) float64 {
+	shift, noiseScale := 0.0, 1.0
+	for _, a := range s.Anomalies {
+		if a.Chart == chart.ID && (a.Dimension == "" || a.Dimension == dim.Name) && t >= a.Start && t <= a.End {
+			shift += a.Shift
+			if a.NoiseScale != 0 {
+				noiseScale *= a.NoiseScale
+			}
+		}
+	}
+	v := dim.Base + shift + dim.Noise*noiseScale*s.normal(chart.ID, dim.Name, t)
+	if dim.Period > 0 {
+		v += dim.Amplitude * math.Sin(2*math.Pi*float64(t)/dim.Period)
+	}
+	return v
+}
+
+// A standard normal that depends only on the seed, chart, dimension and
+// time, so asking for the same point twice gives the same value
+func (s *Server) normal(chart, dim string, t int64) float64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d|%s|%s|%d", s.Seed, chart, dim, t)
+	x := h.Sum64()
+	next := func() float64 {
+		// splitmix64
+		x += 0x9e3779b97f4a7c15
+		z := x
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		z ^= z >> 31
+		return (float64(z>>11) + 0.5) / (1 << 53)
+	}
+	// Box-Muller
+	return math.Sqrt(-2*math.Log(next())) * math.Cos(2*math.Pi*next())
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/api/v1/data":
+		s.serveData(w, r)
+	case "/api/v1/charts":
+		s.serveCharts(w)
+	case "/api/v1/info":
+		writeJSON(w, map[string]interface{}{
+			"version":        "v0.0.0-fake",
+			"uid":            s.Hostname,
+			"mirrored_hosts": []string{s.Hostname},
+			"os_name":        "fake",
+			"charts-count":   len(s.Charts),
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveCharts(w http.ResponseWriter) {
+	resp := netdata.ChartsResponse{
+		Hostname:    s.Hostname,
+		Version:     "v0.0.0-fake",
+		UpdateEvery: 1,
+		ChartsCount: len(s.Charts),
+		Charts:      make(map[string]netdata.Chart),
+	}
+	for _, c := range s.Charts {
+		parts := strings.SplitN(c.ID, ".", 2)
+		chart := netdata.Chart{
+			ID:          c.ID,
+			Name:        c.ID,
+			Type:        parts[0],
+			Family:      c.Family,
+			Context:     c.ID,
+			Title:       c.ID,
+			Units:       c.Units,
+			UpdateEvery: 1,
+			Enabled:     true,
+			Dimensions:  make(map[string]netdata.Dimension),
+		}
+		for _, d := range c.Dimensions {
+			chart.Dimensions[d.Name] = netdata.Dimension{Name: d.Name}
+		}
+		resp.Charts[c.ID] = chart
+	}
+	writeJSON(w, resp)
+}
+
+func (s *Server) serveData(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	chart, ok := s.chart(q.Get("chart"))
+	if !ok {
+		http.Error(w, "Chart is not found: "+q.Get("chart"), http.StatusNotFound)
+		return
+	}
+	after, _ := strconv.ParseInt(q.Get("after"), 10, 64)
+	before, _ := strconv.ParseInt(q.Get("before"), 10, 64)
+	points, _ := strconv.Atoi(q.Get("points"))
+
+	// Relative times work like the real api, after defaults to 10 minutes
+	if before <= 0 {
+		before += s.now()
+	}
+	if after == 0 {
+		after = -600
+	}
+	if after < 0 {
+		after += before
+	}
+
+	// Only what the server has, from History ago to now
+	now := s.now()
+	if before > now {
+		before = now
+	}
+	if first := now - s.History + 1; s.History > 0 && after < first {
+		after = first
+	}
+
+	dims := chart.Dimensions
+	if want := q.Get("dimensions"); want != "" {
+		dims = nil
+		for _, name := range strings.Split(want, "|") {
+			for _, d := range chart.Dimensions {
+				if d.Name == name {
+					dims = append(dims, d)
+				}
+			}
+		}
+	}
+
+	// One row a second, averaged down to points rows if asked, newest first
+	n := before - after + 1
+	if n < 0 {
+		n = 0
+	}
+	group := int64(1)
+	if points > 0 && int64(points) < n {
+		group = (n + int64(points) - 1) / int64(points)
+	}
+	resp := netdata.Response{Labels: []string{"time"}}
+	for _, d := range dims {
+		resp.Labels = append(resp.Labels, d.Name)
+	}
+	for end := before; end >= after; end -= group {
+		row := make([]float64, len(dims)+1)
+		row[0] = float64(end)
+		var count float64
+		for t := end; t > end-group && t >= after; t-- {
+			for j, d := range dims {
+				row[j+1] += s.Value(chart, d, t)
+			}
+			count++
+		}
+		for j := range dims {
+			row[j+1] /= count
+		}
+		resp.Data = append(resp.Data, row)
+	}
+
+	switch q.Get("format") {
+	case "", "json":
+		writeJSON(w, resp)
+	case "csv":
+		seconds := false
+		for _, o := range strings.Split(q.Get("options"), "|") {
+			seconds = seconds || o == "seconds"
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		fmt.Fprintln(w, strings.Join(resp.Labels, ","))
+		for _, row := range resp.Data {
+			fields := make([]string, len(row))
+			if seconds {
+				fields[0] = strconv.FormatInt(int64(row[0]), 10)
+			} else {
+				fields[0] = time.Unix(int64(row[0]), 0).UTC().Format("2006-01-02 15:04:05")
+			}
+			for j, v := range row[1:] {
+				fields[j+1] = strconv.FormatFloat(v, 'f', -1, 64)
+			}
+			fmt.Fprintln(w, strings.Join(fields, ","))
+		}
+	default:
+		http.Error(w, "format not supported by fake netdata: "+q.Get("format"), http.StatusBadRequest)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
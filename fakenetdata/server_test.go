@@ -0,0 +1,275 @@
+package fakenetdata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+// Serve h with httptest and get a client for it
+func newTestServer(t *testing.T, h http.Handler) *netdata.Client {
+	t.Helper()
+	ts := httptest.NewServer(h)
+	t.Cleanup(ts.Close)
+	return netdata.NewClient(ts.URL)
+}
+
+var now = time.Unix(1600000000, 0)
+
+// A server with its clock stopped, so every test sees the same data
+func stopped() *Server {
+	s := New(1)
+	s.Now = func() time.Time { return now }
+	return s
+}
+
+func TestData(t *testing.T) {
+	s := stopped()
+	client := newTestServer(t, s)
+
+	resp, err := client.Data(context.Background(), netdata.DataRequest{Chart: "system.cpu", After: -60})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"time", "user", "system", "iowait"}; len(resp.Labels) != len(want) {
+		t.Fatalf("labels = %v, want %v", resp.Labels, want)
+	}
+	if len(resp.Data) != 61 {
+		t.Fatalf("got %d rows, want 61", len(resp.Data))
+	}
+	newest := resp.Data[0]
+	if int64(newest[0]) != now.Unix() {
+		t.Errorf("newest row at %v, want %v", newest[0], now.Unix())
+	}
+	cpu, _ := s.chart("system.cpu")
+	if want := s.Value(cpu, cpu.Dimensions[0], now.Unix()); newest[1] != want {
+		t.Errorf("user = %v, want %v", newest[1], want)
+	}
+
+	// Averaged down to points
+	resp, err = client.Data(context.Background(), netdata.DataRequest{Chart: "system.cpu", After: -59, Points: 6})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Data) != 6 {
+		t.Errorf("got %d rows with points 6, want 6", len(resp.Data))
+	}
+
+	// Unknown charts are a 404 like the real api
+	_, err = client.Data(context.Background(), netdata.DataRequest{Chart: "nope"})
+	var statusErr *netdata.StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown chart gave %v, want a 404", err)
+	}
+}
+
+func TestDataHistory(t *testing.T) {
+	s := stopped()
+	s.History = 100
+	client := newTestServer(t, s)
+
+	tests := []struct {
+		name          string
+		after, before int64
+		rows          int
+		newest        int64
+	}{
+		{"after cut to history", -1000, 0, 100, now.Unix()},
+		{"absolute after cut too", now.Unix() - 1000, now.Unix(), 100, now.Unix()},
+		{"before cut to now", now.Unix() - 10, now.Unix() + 50, 11, now.Unix()},
+		{"older than history", now.Unix() - 1000, now.Unix() - 500, 0, 0},
+	}
+	for _, tt := range tests {
+		resp, err := client.Data(context.Background(), netdata.DataRequest{Chart: "system.load", After: tt.after, Before: tt.before})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resp.Data) != tt.rows {
+			t.Errorf("%s: got %d rows, want %d", tt.name, len(resp.Data), tt.rows)
+			continue
+		}
+		if tt.rows > 0 && int64(resp.Data[0][0]) != tt.newest {
+			t.Errorf("%s: newest row at %v, want %v", tt.name, resp.Data[0][0], tt.newest)
+		}
+	}
+}
+
+func TestChartsAndDiscover(t *testing.T) {
+	client := newTestServer(t, stopped())
+
+	charts, err := client.Charts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charts.Hostname != "fake-netdata" || len(charts.Charts) != 5 {
+		t.Errorf("got host %q with %d charts, want fake-netdata with 5", charts.Hostname, len(charts.Charts))
+	}
+
+	filter := netdata.ChartFilter{IDs: netdata.Globs{Include: []string{"system.*"}, Exclude: []string{"system.uptime", "system.io"}}}
+	found, err := client.DiscoverCharts(context.Background(), filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, c := range found {
+		ids = append(ids, c.ID)
+	}
+	want := []string{"system.cpu", "system.load", "system.net"}
+	if len(ids) != len(want) {
+		t.Fatalf("discovered %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("discovered %v, want %v", ids, want)
+		}
+	}
+}
+
+func TestPoolFetch(t *testing.T) {
+	client := newTestServer(t, stopped())
+	pool := netdata.NewPool(netdata.PoolOptions{Workers: 2, PerHost: 2})
+	defer pool.Close()
+
+	charts := []string{"system.cpu", "system.load", "system.io", "system.net", "nope"}
+	jobs := make([]netdata.Job, len(charts))
+	for i, chart := range charts {
+		jobs[i] = netdata.Job{Client: client, Request: netdata.DataRequest{Chart: chart, After: -10}}
+	}
+	results := pool.Fetch(context.Background(), jobs)
+	for i, res := range results {
+		if res.Job.Request.Chart != charts[i] {
+			t.Errorf("result %d is for %v, want %v", i, res.Job.Request.Chart, charts[i])
+		}
+		if charts[i] == "nope" {
+			if res.Err == nil || res.Status != http.StatusNotFound {
+				t.Errorf("nope: got status %d err %v, want a 404", res.Status, res.Err)
+			}
+			continue
+		}
+		if res.Err != nil {
+			t.Errorf("%v: %v", charts[i], res.Err)
+			continue
+		}
+		if res.Status != http.StatusOK || len(res.Data.Data) != 11 {
+			t.Errorf("%v: got status %d and %d rows, want 200 and 11", charts[i], res.Status, len(res.Data.Data))
+		}
+	}
+}
+
+// Fails the first n requests with status, then hands over to h
+func failing(n int32, status int, h http.Handler) (http.Handler, *int32) {
+	var calls int32
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= n {
+			http.Error(w, "try again", status)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}), &calls
+}
+
+func TestRetry(t *testing.T) {
+	policy := netdata.DefaultRetryPolicy
+	policy.BaseDelay = time.Millisecond
+
+	// Two 503s then success, within three attempts
+	h, calls := failing(2, http.StatusServiceUnavailable, stopped())
+	client := newTestServer(t, h)
+	client.Retry = &policy
+	if _, err := client.Data(context.Background(), netdata.DataRequest{Chart: "system.cpu"}); err != nil {
+		t.Errorf("retried 503s: %v", err)
+	}
+	if *calls != 3 {
+		t.Errorf("made %d calls, want 3", *calls)
+	}
+
+	// Out of attempts
+	h, calls = failing(5, http.StatusServiceUnavailable, stopped())
+	client = newTestServer(t, h)
+	client.Retry = &policy
+	if _, err := client.Data(context.Background(), netdata.DataRequest{Chart: "system.cpu"}); err == nil {
+		t.Error("got no error after running out of attempts")
+	}
+	if *calls != 3 {
+		t.Errorf("made %d calls, want 3", *calls)
+	}
+
+	// Not retried, a 400 won't get better
+	h, calls = failing(1, http.StatusBadRequest, stopped())
+	client = newTestServer(t, h)
+	client.Retry = &policy
+	if _, err := client.Data(context.Background(), netdata.DataRequest{Chart: "system.cpu"}); err == nil {
+		t.Error("got no error for a 400")
+	}
+	if *calls != 1 {
+		t.Errorf("made %d calls for a 400, want 1", *calls)
+	}
+}
+
+// Holds requests for chart for delay before handing over to h, or until the
+// client gives up
+func slow(chart string, delay time.Duration, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("chart") == chart {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestTimeouts(t *testing.T) {
+	charts := []string{"system.cpu", "system.io", "system.load"}
+	fetch := func(ctx context.Context, client *netdata.Client) ([]netdata.Result, time.Duration) {
+		pool := netdata.NewPool(netdata.PoolOptions{Workers: 3, PerHost: 3})
+		defer pool.Close()
+		jobs := make([]netdata.Job, len(charts))
+		for i, chart := range charts {
+			jobs[i] = netdata.Job{Client: client, Request: netdata.DataRequest{Chart: chart, After: -10}}
+		}
+		start := time.Now()
+		results := pool.Fetch(ctx, jobs)
+		return results, time.Since(start)
+	}
+	check := func(results []netdata.Result) {
+		t.Helper()
+		for i, res := range results {
+			if charts[i] == "system.io" {
+				if !netdata.IsTimeout(res.Err) {
+					t.Errorf("slow chart gave %v, want a timeout", res.Err)
+				}
+			} else if res.Err != nil {
+				t.Errorf("%v: %v", charts[i], res.Err)
+			}
+		}
+	}
+
+	// The client's per request timeout gives up on the slow chart, the rest
+	// still come back
+	client := newTestServer(t, slow("system.io", 5*time.Second, stopped()))
+	client.Timeout = 100 * time.Millisecond
+	results, took := fetch(context.Background(), client)
+	check(results)
+	if took > 2*time.Second {
+		t.Errorf("fetch took %v with a 100ms request timeout", took)
+	}
+
+	// As does a step or train deadline on the whole fetch
+	client = newTestServer(t, slow("system.io", 5*time.Second, stopped()))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	results, took = fetch(ctx, client)
+	check(results)
+	if took > 2*time.Second {
+		t.Errorf("fetch took %v with a 200ms deadline", took)
+	}
+}
@@ -0,0 +1,241 @@
+package features
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Align says which times a joined frame has rows for
+type Align string
+
+// Alignments
+const (
+	// Inner keeps only times every frame has
+	Inner Align = "inner"
+
+	// Outer keeps times any frame has, the gaps are filled in by a Fill
+	Outer Align = "outer"
+)
+
+// Valid reports whether a is a known alignment
+func (a Align) Valid() bool {
+	return a == Inner || a == Outer
+}
+
+// Fill says what to do with values missing after an outer join
+type Fill string
+
+// Ways of filling missing values
+const (
+	// Drop drops rows with any missing values
+	Drop Fill = "drop"
+
+	// FFill carries the last value forward, rows before a column's first
+	// value are dropped
+	FFill Fill = "ffill"
+
+	// Zero fills with 0
+	Zero Fill = "zero"
+
+	// Linear interpolates between the values either side in time, the ends
+	// take the nearest value
+	Linear Fill = "linear"
+)
+
+// Valid reports whether f is a known fill
+func (f Fill) Valid() bool {
+	switch f {
+	case Drop, FFill, Zero, Linear:
+		return true
+	}
+	return false
+}
+
+// JoinOptions says how to line up frames in Join
+type JoinOptions struct {
+	Align Align `json:"align"`
+	Fill  Fill  `json:"fill"`
+
+	// Resolution rounds times down to a multiple of this many seconds before
+	// joining, so charts collected a moment apart still line up. Rows that
+	// round to the same time are averaged. 0 or 1 leaves times alone.
+	Resolution int64 `json:"resolution"`
+}
+
+// Join lines frames up on time into one frame, the columns of frames[i]
+// are named prefixes[i]+"|"+name, so "system.cpu|user"
+func Join(prefixes []string, frames []Frame, opts JoinOptions) (Frame, error) {
+	if len(prefixes) != len(frames) {
+		return Frame{}, fmt.Errorf("features: %d prefixes for %d frames", len(prefixes), len(frames))
+	}
+	if !opts.Align.Valid() {
+		return Frame{}, fmt.Errorf("features: unknown alignment %q", opts.Align)
+	}
+	if !opts.Fill.Valid() {
+		return Frame{}, fmt.Errorf("features: unknown fill %q", opts.Fill)
+	}
+
+	// Each frame's rows by rounded time, and how many frames have each time
+	var names []string
+	named := make(map[string]bool)
+	byTime := make([]map[int64][]float64, len(frames))
+	seen := make(map[int64]int)
+	for i, f := range frames {
+		for _, name := range f.Names {
+			name = prefixes[i] + "|" + name
+			if named[name] {
+				return Frame{}, fmt.Errorf("features: joining makes two columns named %q", name)
+			}
+			named[name] = true
+			names = append(names, name)
+		}
+		byTime[i] = roundTimes(f, opts.Resolution)
+		for t := range byTime[i] {
+			seen[t]++
+		}
+	}
+
+	var times []int64
+	for t, n := range seen {
+		if opts.Align == Outer || n == len(frames) {
+			times = append(times, t)
+		}
+	}
+	sort.Slice(times, func(a, b int) bool { return times[a] < times[b] })
+
+	// One column per dimension, NaN where a frame has no row
+	cols := make([][]float64, 0, len(names))
+	for i, f := range frames {
+		for j := range f.Names {
+			col := make([]float64, len(times))
+			for r, t := range times {
+				if row, ok := byTime[i][t]; ok {
+					col[r] = row[j]
+				} else {
+					col[r] = math.NaN()
+				}
+			}
+			cols = append(cols, col)
+		}
+	}
+
+	times, cols = fill(times, cols, opts.Fill)
+	return withRows(names, times, cols), nil
+}
+
+// FillMissing fills the NaNs in f the way Join does
+func FillMissing(f Frame, how Fill) (Frame, error) {
+	if !how.Valid() {
+		return Frame{}, fmt.Errorf("features: unknown fill %q", how)
+	}
+	cols := make([][]float64, len(f.Names))
+	for j := range cols {
+		cols[j] = f.Col(j)
+	}
+	times, cols := fill(append([]int64(nil), f.Times...), cols, how)
+	return withRows(f.Names, times, cols), nil
+}
+
+// Rows of f keyed by time rounded down to resolution, averaging rows that
+// land on the same time
+func roundTimes(f Frame, resolution int64) map[int64][]float64 {
+	sums := make(map[int64][]float64)
+	counts := make(map[int64]int)
+	for r, t := range f.Times {
+		if resolution > 1 {
+			t -= t % resolution
+		}
+		if sums[t] == nil {
+			sums[t] = make([]float64, len(f.Names))
+		}
+		for j, v := range f.Rows[r] {
+			sums[t][j] += v
+		}
+		counts[t]++
+	}
+	for t, sum := range sums {
+		for j := range sum {
+			sum[j] /= float64(counts[t])
+		}
+	}
+	return sums
+}
+
+// Fill the NaNs in cols, dropping rows when the fill says to
+func fill(times []int64, cols [][]float64, how Fill) ([]int64, [][]float64) {
+	for _, col := range cols {
+		switch how {
+		case FFill:
+			for r := 1; r < len(col); r++ {
+				if math.IsNaN(col[r]) {
+					col[r] = col[r-1]
+				}
+			}
+		case Zero:
+			for r := range col {
+				if math.IsNaN(col[r]) {
+					col[r] = 0
+				}
+			}
+		case Linear:
+			interpolate(times, col)
+		}
+	}
+
+	// Anything still missing, all of them for Drop, takes its row with it
+	keep := make([]int, 0, len(times))
+	for r := range times {
+		ok := true
+		for _, col := range cols {
+			if math.IsNaN(col[r]) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			keep = append(keep, r)
+		}
+	}
+	if len(keep) == len(times) {
+		return times, cols
+	}
+	kept := make([]int64, len(keep))
+	for i, r := range keep {
+		kept[i] = times[r]
+	}
+	for j, col := range cols {
+		c := make([]float64, len(keep))
+		for i, r := range keep {
+			c[i] = col[r]
+		}
+		cols[j] = c
+	}
+	return kept, cols
+}
+
+// Fill NaNs in col by interpolating in time between the nearest values
+// either side, or copying the nearest value at the ends
+func interpolate(times []int64, col []float64) {
+	prev := -1
+	for r := 0; r <= len(col); r++ {
+		if r < len(col) && math.IsNaN(col[r]) {
+			continue
+		}
+		// Fill the gap between prev and r
+		for g := prev + 1; g < r; g++ {
+			switch {
+			case prev < 0 && r == len(col):
+				// Nothing to go on
+			case prev < 0:
+				col[g] = col[r]
+			case r == len(col):
+				col[g] = col[prev]
+			default:
+				w := float64(times[g]-times[prev]) / float64(times[r]-times[prev])
+				col[g] = col[prev] + w*(col[r]-col[prev])
+			}
+		}
+		prev = r
+	}
+}
@@ -0,0 +1,148 @@
+package features
+
+import (
+	"math"
+	"testing"
+)
+
+// a has a row every second, b misses 10 and 12 and runs on to 14
+func joinFrames() []Frame {
+	return []Frame{
+		{Names: []string{"x"}, Times: []int64{10, 11, 12, 13}, Rows: [][]float64{{1}, {2}, {3}, {4}}},
+		{Names: []string{"x"}, Times: []int64{11, 13, 14}, Rows: [][]float64{{10}, {30}, {40}}},
+	}
+}
+
+func TestJoin(t *testing.T) {
+	names := []string{"a|x", "b|x"}
+	tests := []struct {
+		name string
+		opts JoinOptions
+		want Frame
+	}{
+		{"inner", JoinOptions{Align: Inner, Fill: Drop}, Frame{
+			Names: names,
+			Times: []int64{11, 13},
+			Rows:  [][]float64{{2, 10}, {4, 30}},
+		}},
+		{"outer drop", JoinOptions{Align: Outer, Fill: Drop}, Frame{
+			Names: names,
+			Times: []int64{11, 13},
+			Rows:  [][]float64{{2, 10}, {4, 30}},
+		}},
+		// b has nothing to carry forward into 10, so that row goes
+		{"outer ffill", JoinOptions{Align: Outer, Fill: FFill}, Frame{
+			Names: names,
+			Times: []int64{11, 12, 13, 14},
+			Rows:  [][]float64{{2, 10}, {3, 10}, {4, 30}, {4, 40}},
+		}},
+		{"outer zero", JoinOptions{Align: Outer, Fill: Zero}, Frame{
+			Names: names,
+			Times: []int64{10, 11, 12, 13, 14},
+			Rows:  [][]float64{{1, 0}, {2, 10}, {3, 0}, {4, 30}, {0, 40}},
+		}},
+		// The leading gap in b and trailing one in a take the nearest value
+		{"outer linear", JoinOptions{Align: Outer, Fill: Linear}, Frame{
+			Names: names,
+			Times: []int64{10, 11, 12, 13, 14},
+			Rows:  [][]float64{{1, 10}, {2, 10}, {3, 20}, {4, 30}, {4, 40}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frames := joinFrames()
+			got, err := Join([]string{"a", "b"}, frames, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFrame(t, got, tt.want)
+			for j := range names {
+				if col := got.Col(j); len(col) > 0 && math.IsNaN(col[0]) {
+					t.Errorf("%v has NaNs left in it", got.Names[j])
+				}
+			}
+			checkFrame(t, frames[1], joinFrames()[1])
+		})
+	}
+}
+
+func TestJoinResolutions(t *testing.T) {
+	// a every second, c every two
+	a := Frame{Names: []string{"x"}, Times: []int64{10, 11, 12, 13}, Rows: [][]float64{{1}, {2}, {3}, {4}}}
+	c := Frame{Names: []string{"y"}, Times: []int64{10, 12, 14}, Rows: [][]float64{{100}, {120}, {140}}}
+	names := []string{"a|x", "c|y"}
+	tests := []struct {
+		name string
+		opts JoinOptions
+		want Frame
+	}{
+		// Only the times both have
+		{"as they are", JoinOptions{Align: Inner, Fill: Drop}, Frame{
+			Names: names,
+			Times: []int64{10, 12},
+			Rows:  [][]float64{{1, 100}, {3, 120}},
+		}},
+		// a is averaged into two second rows
+		{"rounded to 2s", JoinOptions{Align: Inner, Fill: Drop, Resolution: 2}, Frame{
+			Names: names,
+			Times: []int64{10, 12},
+			Rows:  [][]float64{{1.5, 100}, {3.5, 120}},
+		}},
+		{"rounded to 2s outer ffill", JoinOptions{Align: Outer, Fill: FFill, Resolution: 2}, Frame{
+			Names: names,
+			Times: []int64{10, 12, 14},
+			Rows:  [][]float64{{1.5, 100}, {3.5, 120}, {3.5, 140}},
+		}},
+		{"outer linear", JoinOptions{Align: Outer, Fill: Linear}, Frame{
+			Names: names,
+			Times: []int64{10, 11, 12, 13, 14},
+			Rows:  [][]float64{{1, 100}, {2, 110}, {3, 120}, {4, 130}, {4, 140}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Join([]string{"a", "c"}, []Frame{a, c}, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFrame(t, got, tt.want)
+		})
+	}
+}
+
+func TestJoinDuplicateNames(t *testing.T) {
+	cpu := Frame{Names: []string{"user", "system"}, Times: []int64{10, 11}, Rows: [][]float64{{1, 2}, {3, 4}}}
+	other := Frame{Names: []string{"user", "system"}, Times: []int64{10, 11}, Rows: [][]float64{{5, 6}, {7, 8}}}
+	opts := JoinOptions{Align: Inner, Fill: Drop}
+
+	// The same dimension names on different charts are kept apart
+	got, err := Join([]string{"system.cpu", "app.cpu"}, []Frame{cpu, other}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFrame(t, got, Frame{
+		Names: []string{"system.cpu|user", "system.cpu|system", "app.cpu|user", "app.cpu|system"},
+		Times: []int64{10, 11},
+		Rows:  [][]float64{{1, 2, 5, 6}, {3, 4, 7, 8}},
+	})
+
+	// The same chart twice can't be
+	if _, err := Join([]string{"system.cpu", "system.cpu"}, []Frame{cpu, other}, opts); err == nil {
+		t.Error("joining a chart to itself: got no error")
+	}
+}
+
+func TestJoinErrors(t *testing.T) {
+	frames := joinFrames()
+	for name, opts := range map[string]JoinOptions{
+		"unknown align": {Align: "left", Fill: Drop},
+		"unknown fill":  {Align: Outer, Fill: "mean"},
+	} {
+		if _, err := Join([]string{"a", "b"}, frames, opts); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+	if _, err := Join([]string{"a"}, frames, JoinOptions{Align: Inner, Fill: Drop}); err == nil {
+		t.Error("too few prefixes: got no error")
+	}
+}
@@ -0,0 +1,205 @@
+package features
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/netdata"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Frame is a time series on its way through a Pipeline, one row per time
+// step oldest first and a name for each column. Times holds the source
+// timestamp of each row.
+type Frame struct {
+	Names []string
+	Times []int64
+	Rows  [][]float64
+}
+
+// FromResponse makes a Frame from a netdata response, moving the time
+// column into Times. Netdata sends the latest row first by default so rows
+// are sorted oldest first. A response without labels, as from an empty
+// body, or with rows that don't match its labels is an error.
+func FromResponse(r *netdata.Response) (Frame, error) {
+	if r == nil || len(r.Labels) == 0 {
+		return Frame{}, fmt.Errorf("features: response has no labels")
+	}
+	for i, row := range r.Data {
+		if len(row) != len(r.Labels) {
+			return Frame{}, fmt.Errorf("features: response row %d has %d values for %d labels", i, len(row), len(r.Labels))
+		}
+	}
+	f := Frame{Names: append([]string(nil), r.Labels[1:]...)}
+	rows := append([][]float64(nil), r.Data...)
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+	f.Times = make([]int64, len(rows))
+	f.Rows = make([][]float64, len(rows))
+	for t, row := range rows {
+		f.Times[t] = int64(row[0])
+		f.Rows[t] = append([]float64(nil), row[1:]...)
+	}
+	return f, nil
+}
+
+// FromColumns makes a Frame from one slice of values per column, with
+// times being just the row numbers
+func FromColumns(names []string, cols [][]float64) Frame {
+	nRows := 0
+	if len(cols) > 0 {
+		nRows = len(cols[0])
+	}
+	times := make([]int64, nRows)
+	for t := range times {
+		times[t] = int64(t)
+	}
+	return withRows(names, times, cols)
+}
+
+// Col returns a copy of column j
+func (f Frame) Col(j int) []float64 {
+	col := make([]float64, len(f.Rows))
+	for t := range f.Rows {
+		col[t] = f.Rows[t][j]
+	}
+	return col
+}
+
+func withRows(names []string, times []int64, cols [][]float64) Frame {
+	f := Frame{Names: names, Times: times, Rows: make([][]float64, len(times))}
+	for t := range f.Rows {
+		f.Rows[t] = make([]float64, len(cols))
+		for j := range cols {
+			f.Rows[t][j] = cols[j][t]
+		}
+	}
+	return f
+}
+
+// Step is one transform in a Pipeline
+type Step interface {
+	Apply(f Frame) (Frame, error)
+
+	// Shape gives the rows and columns Apply would return for a frame of
+	// the given size, rows may come out negative if it is too short
+	Shape(rows, cols int) (int, int)
+}
+
+// A step that has to learn something from training data, like the scale
+// of each column, before it can Apply
+type fitter interface {
+	Step
+	Fit(f Frame) error
+}
+
+// Matrix is a feature matrix with a name for each column and the source
+// timestamp of each row
+type Matrix struct {
+	*mat.Dense
+	Names []string
+	Times []int64
+}
+
+// TooShortError is returned when there are not enough rows for a pipeline
+// to make even one row of features
+type TooShortError struct {
+	Rows    int
+	MinRows int
+}
+
+func (e *TooShortError) Error() string {
+	return fmt.Sprintf("features: got %d rows but need at least %d to make any features", e.Rows, e.MinRows)
+}
+
+// Pipeline runs its steps in order to turn a Frame into a feature Matrix
+type Pipeline struct {
+	Steps []Step
+}
+
+// NewPipeline makes a pipeline from steps
+func NewPipeline(steps ...Step) *Pipeline {
+	return &Pipeline{Steps: steps}
+}
+
+// Standard is the smoothing, then diffs, then lags pipeline the netdata
+// scripts have always used. For n rows of d dimensions it makes a matrix
+// of n-diffs-lags rows and d*(lags+1) columns.
+func Standard(lags, diffs, smoothing int, method SmoothMethod) *Pipeline {
+	return NewPipeline(
+		Smoothing{Window: smoothing, Method: method},
+		Diff{N: diffs},
+		Lag{N: lags},
+	)
+}
+
+// Shape gives the size of the matrix the pipeline makes from rows by cols
+// of input, rows may come out zero or negative if the input is too short
+func (p *Pipeline) Shape(rows, cols int) (int, int) {
+	for _, step := range p.Steps {
+		rows, cols = step.Shape(rows, cols)
+	}
+	return rows, cols
+}
+
+// MinRows is the fewest input rows that make at least one row of features
+func (p *Pipeline) MinRows() int {
+	rows, _ := p.Shape(0, 1)
+	return 1 - rows
+}
+
+// Fit runs the pipeline on training data, fitting any steps that need it
+// on their way through
+func (p *Pipeline) Fit(f Frame) (*Matrix, error) {
+	if err := p.check(f); err != nil {
+		return nil, err
+	}
+	var err error
+	for _, step := range p.Steps {
+		if s, ok := step.(fitter); ok {
+			if err = s.Fit(f); err != nil {
+				return nil, err
+			}
+		}
+		if f, err = step.Apply(f); err != nil {
+			return nil, err
+		}
+	}
+	return toMatrix(f)
+}
+
+// Transform runs the pipeline on f using whatever was learnt in Fit
+func (p *Pipeline) Transform(f Frame) (*Matrix, error) {
+	if err := p.check(f); err != nil {
+		return nil, err
+	}
+	var err error
+	for _, step := range p.Steps {
+		if f, err = step.Apply(f); err != nil {
+			return nil, err
+		}
+	}
+	return toMatrix(f)
+}
+
+// Check up front that f is long enough and has some columns
+func (p *Pipeline) check(f Frame) error {
+	if len(f.Names) == 0 {
+		return fmt.Errorf("features: no columns to make features from")
+	}
+	if rows, _ := p.Shape(len(f.Rows), len(f.Names)); rows < 1 {
+		return &TooShortError{Rows: len(f.Rows), MinRows: p.MinRows()}
+	}
+	return nil
+}
+
+func toMatrix(f Frame) (*Matrix, error) {
+	nRows, nCols := len(f.Rows), len(f.Names)
+	if nRows == 0 || nCols == 0 {
+		return nil, fmt.Errorf("features: no rows or columns left to make a matrix from")
+	}
+	dataFlat := make([]float64, 0, nRows*nCols)
+	for _, row := range f.Rows {
+		dataFlat = append(dataFlat, row...)
+	}
+	return &Matrix{Dense: mat.NewDense(nRows, nCols, dataFlat), Names: f.Names, Times: f.Times}, nil
+}
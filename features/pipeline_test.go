@@ -0,0 +1,109 @@
+package features
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+func TestFromResponse(t *testing.T) {
+	// Latest row first, as netdata sends it
+	got, err := FromResponse(&netdata.Response{
+		Labels: []string{"time", "a", "b"},
+		Data:   [][]float64{{12, 4, 5}, {11, 2, 5}, {10, 1, 5}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFrame(t, got, Frame{
+		Names: []string{"a", "b"},
+		Times: []int64{10, 11, 12},
+		Rows:  [][]float64{{1, 5}, {2, 5}, {4, 5}},
+	})
+
+	for name, r := range map[string]*netdata.Response{
+		"nil":       nil,
+		"no labels": {Data: [][]float64{{10, 1}}},
+		"short row": {Labels: []string{"time", "a", "b"}, Data: [][]float64{{11, 2, 5}, {10, 1}}},
+		"long row":  {Labels: []string{"time", "a"}, Data: [][]float64{{10, 1, 5}}},
+		"empty row": {Labels: []string{"time", "a"}, Data: [][]float64{{}}},
+	} {
+		if _, err := FromResponse(r); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestMinRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline *Pipeline
+		want     int
+	}{
+		{"empty", NewPipeline(), 1},
+		{"lag 3", NewPipeline(Lag{N: 3}), 4},
+		{"diff 2", NewPipeline(Diff{N: 2}), 3},
+		{"smoothing keeps rows", NewPipeline(Smoothing{Window: 5, Method: Mean}), 1},
+		{"rolling stats keep rows", NewPipeline(RollingStats{Window: 4, Stats: []string{"mean"}}), 1},
+		{"standard defaults", Standard(1, 1, 3, Mean), 3},
+		{"standard", Standard(5, 2, 3, EWMA), 8},
+		{"standard no features", Standard(0, 0, 0, Mean), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pipeline.MinRows(); got != tt.want {
+				t.Fatalf("MinRows = %d, want %d", got, tt.want)
+			}
+
+			// MinRows rows make exactly one row of features
+			f := Frame{Names: []string{"a"}}
+			for i := 0; i < tt.want; i++ {
+				f.Times = append(f.Times, int64(i))
+				f.Rows = append(f.Rows, []float64{float64(i * i)})
+			}
+			x, err := tt.pipeline.Transform(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rows, _ := x.Dims(); rows != 1 {
+				t.Errorf("%d rows made %d rows of features, want 1", tt.want, rows)
+			}
+		})
+	}
+}
+
+func TestTooShortError(t *testing.T) {
+	tests := []struct {
+		name     string
+		pipeline *Pipeline
+		rows     int
+	}{
+		{"no rows", NewPipeline(), 0},
+		{"lag 3", NewPipeline(Lag{N: 3}), 3},
+		{"diff 2", NewPipeline(Diff{N: 2}), 1},
+		{"standard", Standard(5, 2, 3, EWMA), 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := Frame{Names: []string{"a"}}
+			for i := 0; i < tt.rows; i++ {
+				f.Times = append(f.Times, int64(i))
+				f.Rows = append(f.Rows, []float64{float64(i)})
+			}
+			for name, run := range map[string]func(Frame) (*Matrix, error){
+				"Fit":       tt.pipeline.Fit,
+				"Transform": tt.pipeline.Transform,
+			} {
+				_, err := run(f)
+				var short *TooShortError
+				if !errors.As(err, &short) {
+					t.Fatalf("%s: got %v, want a TooShortError", name, err)
+				}
+				if short.Rows != tt.rows || short.MinRows != tt.pipeline.MinRows() {
+					t.Errorf("%s: got %+v, want Rows %d and MinRows %d", name, short, tt.rows, tt.pipeline.MinRows())
+				}
+			}
+		})
+	}
+}
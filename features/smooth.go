@@ -0,0 +1,91 @@
+// Package features turns netdata time series into feature matrices for models
+package features
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SmoothMethod names a way of smoothing a series
+type SmoothMethod string
+
+// Smoothing methods, all use a trailing window ending at the current point
+const (
+	// Mean is a simple moving average
+	Mean SmoothMethod = "mean"
+
+	// EWMA is an exponentially weighted moving average with alpha = 2/(window+1)
+	EWMA SmoothMethod = "ewma"
+
+	// Median is a rolling median
+	Median SmoothMethod = "median"
+)
+
+// Valid reports whether m is a known smoothing method
+func (m SmoothMethod) Valid() bool {
+	switch m {
+	case Mean, EWMA, Median:
+		return true
+	}
+	return false
+}
+
+// Smooth smooths x with a trailing window of the given size. The output is
+// the same length as x, the first few points just use as much window as
+// they have. A window of 0 or 1 leaves x as it is.
+func Smooth(x []float64, window int, method SmoothMethod) ([]float64, error) {
+	if !method.Valid() {
+		return nil, fmt.Errorf("features: unknown smoothing method %q", method)
+	}
+	if window < 0 {
+		return nil, fmt.Errorf("features: smoothing window must be >= 0, got %d", window)
+	}
+
+	smoothed := make([]float64, len(x))
+	if window <= 1 {
+		copy(smoothed, x)
+		return smoothed, nil
+	}
+
+	switch method {
+	case Mean:
+		var sum float64
+		for t := range x {
+			sum += x[t]
+			if t >= window {
+				sum -= x[t-window]
+			}
+			n := t + 1
+			if n > window {
+				n = window
+			}
+			smoothed[t] = sum / float64(n)
+		}
+	case EWMA:
+		alpha := 2 / (float64(window) + 1)
+		for t := range x {
+			if t == 0 {
+				smoothed[t] = x[t]
+				continue
+			}
+			smoothed[t] = alpha*x[t] + (1-alpha)*smoothed[t-1]
+		}
+	case Median:
+		buf := make([]float64, 0, window)
+		for t := range x {
+			start := t - window + 1
+			if start < 0 {
+				start = 0
+			}
+			buf = append(buf[:0], x[start:t+1]...)
+			sort.Float64s(buf)
+			n := len(buf)
+			if n%2 == 1 {
+				smoothed[t] = buf[n/2]
+			} else {
+				smoothed[t] = (buf[n/2-1] + buf[n/2]) / 2
+			}
+		}
+	}
+	return smoothed, nil
+}
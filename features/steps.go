@@ -0,0 +1,262 @@
+package features
+
+import (
+	"fmt"
+	"math"
+)
+
+// Smoothing smooths every column with a trailing window, see Smooth for details
+type Smoothing struct {
+	Window int
+	Method SmoothMethod
+}
+
+// Apply implements Step
+func (s Smoothing) Apply(f Frame) (Frame, error) {
+	cols := make([][]float64, len(f.Names))
+	for j := range f.Names {
+		smoothed, err := Smooth(f.Col(j), s.Window, s.Method)
+		if err != nil {
+			return Frame{}, err
+		}
+		cols[j] = smoothed
+	}
+	return withRows(f.Names, f.Times, cols), nil
+}
+
+// Shape implements Step
+func (s Smoothing) Shape(rows, cols int) (int, int) {
+	return rows, cols
+}
+
+// Diff takes the difference of order N of each column, the first
+// difference x[t]-x[t-1] taken N times over, dropping the first N rows. N of
+// 1 removes a trend, 2 a changing one. N of 0 leaves the frame as it is.
+type Diff struct {
+	N int
+}
+
+// Apply implements Step
+func (d Diff) Apply(f Frame) (Frame, error) {
+	if d.N < 0 {
+		return Frame{}, fmt.Errorf("features: diff must be >= 0, got %d", d.N)
+	}
+	if d.N == 0 {
+		return f, nil
+	}
+	if len(f.Rows) <= d.N {
+		return Frame{Names: f.Names}, nil
+	}
+	cols := make([][]float64, len(f.Names))
+	for j := range cols {
+		col := f.Col(j)
+		for n := 0; n < d.N; n++ {
+			for t := 0; t < len(col)-1; t++ {
+				col[t] = col[t+1] - col[t]
+			}
+			col = col[:len(col)-1]
+		}
+		cols[j] = col
+	}
+	return withRows(f.Names, f.Times[d.N:], cols), nil
+}
+
+// Shape implements Step
+func (d Diff) Shape(rows, cols int) (int, int) {
+	return rows - d.N, cols
+}
+
+// Lag adds the previous N values of each column alongside it, dropping
+// the first N rows. Columns come out grouped by the column they came from,
+// so "in", "in|lag1", ..., "out", "out|lag1", ...
+type Lag struct {
+	N int
+}
+
+// Apply implements Step
+func (l Lag) Apply(f Frame) (Frame, error) {
+	if l.N < 0 {
+		return Frame{}, fmt.Errorf("features: lags must be >= 0, got %d", l.N)
+	}
+	if l.N == 0 {
+		return f, nil
+	}
+	out := Frame{}
+	for _, name := range f.Names {
+		out.Names = append(out.Names, name)
+		for lag := 1; lag <= l.N; lag++ {
+			out.Names = append(out.Names, fmt.Sprintf("%s|lag%d", name, lag))
+		}
+	}
+	if len(f.Rows) > l.N {
+		out.Times = f.Times[l.N:]
+	}
+	for t := l.N; t < len(f.Rows); t++ {
+		row := make([]float64, 0, len(out.Names))
+		for j := range f.Names {
+			for lag := 0; lag <= l.N; lag++ {
+				row = append(row, f.Rows[t-lag][j])
+			}
+		}
+		out.Rows = append(out.Rows, row)
+	}
+	return out, nil
+}
+
+// Shape implements Step
+func (l Lag) Shape(rows, cols int) (int, int) {
+	return rows - l.N, cols * (l.N + 1)
+}
+
+// ScaleMethod names a way of scaling columns
+type ScaleMethod string
+
+// Scaling methods
+const (
+	// Standardize scales each column to zero mean and unit variance
+	Standardize ScaleMethod = "standard"
+
+	// MinMax scales each column into [0, 1]
+	MinMax ScaleMethod = "minmax"
+)
+
+// Scale scales each column using the centre and spread it saw in Fit, so
+// new data is scaled the same way as the training data was
+type Scale struct {
+	Method ScaleMethod
+
+	center []float64
+	spread []float64
+}
+
+// Fit learns the centre and spread of each column
+func (s *Scale) Fit(f Frame) error {
+	if s.Method != Standardize && s.Method != MinMax {
+		return fmt.Errorf("features: unknown scale method %q", s.Method)
+	}
+	s.center = make([]float64, len(f.Names))
+	s.spread = make([]float64, len(f.Names))
+	for j := range f.Names {
+		col := f.Col(j)
+		switch s.Method {
+		case Standardize:
+			s.center[j], s.spread[j] = meanStd(col)
+		case MinMax:
+			lo, hi := minMax(col)
+			s.center[j], s.spread[j] = lo, hi-lo
+		}
+		// Constant columns are left as they are, just shifted
+		if s.spread[j] == 0 {
+			s.spread[j] = 1
+		}
+	}
+	return nil
+}
+
+// Apply implements Step
+func (s *Scale) Apply(f Frame) (Frame, error) {
+	if len(s.center) != len(f.Names) {
+		return Frame{}, fmt.Errorf("features: scale fitted on %d columns, got %d", len(s.center), len(f.Names))
+	}
+	out := Frame{Names: f.Names, Times: f.Times, Rows: make([][]float64, len(f.Rows))}
+	for t, row := range f.Rows {
+		out.Rows[t] = make([]float64, len(row))
+		for j, v := range row {
+			out.Rows[t][j] = (v - s.center[j]) / s.spread[j]
+		}
+	}
+	return out, nil
+}
+
+// Shape implements Step
+func (s *Scale) Shape(rows, cols int) (int, int) {
+	return rows, cols
+}
+
+// RollingStats adds trailing window statistics of each column alongside
+// it, named like "in|mean5". As with Smoothing the first few rows use as much
+// window as they have. Stats can be "mean", "std", "min" and "max".
+type RollingStats struct {
+	Window int
+	Stats  []string
+}
+
+// Apply implements Step
+func (r RollingStats) Apply(f Frame) (Frame, error) {
+	if r.Window < 1 {
+		return Frame{}, fmt.Errorf("features: rolling window must be >= 1, got %d", r.Window)
+	}
+	for _, stat := range r.Stats {
+		switch stat {
+		case "mean", "std", "min", "max":
+		default:
+			return Frame{}, fmt.Errorf("features: unknown rolling stat %q", stat)
+		}
+	}
+
+	var names []string
+	var cols [][]float64
+	for j, name := range f.Names {
+		col := f.Col(j)
+		names = append(names, name)
+		cols = append(cols, col)
+		for _, stat := range r.Stats {
+			names = append(names, fmt.Sprintf("%s|%s%d", name, stat, r.Window))
+			cols = append(cols, rolling(col, r.Window, stat))
+		}
+	}
+	return withRows(names, f.Times, cols), nil
+}
+
+// Shape implements Step
+func (r RollingStats) Shape(rows, cols int) (int, int) {
+	return rows, cols * (len(r.Stats) + 1)
+}
+
+func rolling(x []float64, window int, stat string) []float64 {
+	out := make([]float64, len(x))
+	for t := range x {
+		start := t - window + 1
+		if start < 0 {
+			start = 0
+		}
+		w := x[start : t+1]
+		switch stat {
+		case "mean":
+			out[t], _ = meanStd(w)
+		case "std":
+			_, out[t] = meanStd(w)
+		case "min":
+			out[t], _ = minMax(w)
+		case "max":
+			_, out[t] = minMax(w)
+		}
+	}
+	return out
+}
+
+func meanStd(x []float64) (mean, std float64) {
+	if len(x) == 0 {
+		return 0, 0
+	}
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+	for _, v := range x {
+		std += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(x)))
+}
+
+func minMax(x []float64) (lo, hi float64) {
+	if len(x) == 0 {
+		return 0, 0
+	}
+	lo, hi = x[0], x[0]
+	for _, v := range x {
+		lo = math.Min(lo, v)
+		hi = math.Max(hi, v)
+	}
+	return lo, hi
+}
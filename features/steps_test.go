@@ -0,0 +1,190 @@
+package features
+
+import (
+	"math"
+	"testing"
+)
+
+// Five rows of a rising column and a constant one
+func testFrame() Frame {
+	return Frame{
+		Names: []string{"a", "b"},
+		Times: []int64{10, 11, 12, 13, 14},
+		Rows:  [][]float64{{1, 5}, {2, 5}, {4, 5}, {7, 5}, {11, 5}},
+	}
+}
+
+func checkFrame(t *testing.T, got, want Frame) {
+	t.Helper()
+	if len(got.Names) != len(want.Names) {
+		t.Fatalf("names = %v, want %v", got.Names, want.Names)
+	}
+	for j := range want.Names {
+		if got.Names[j] != want.Names[j] {
+			t.Fatalf("names = %v, want %v", got.Names, want.Names)
+		}
+	}
+	if len(got.Times) != len(want.Times) || len(got.Rows) != len(want.Rows) {
+		t.Fatalf("got %d times and %d rows, want %d and %d", len(got.Times), len(got.Rows), len(want.Times), len(want.Rows))
+	}
+	for i := range want.Rows {
+		if got.Times[i] != want.Times[i] {
+			t.Errorf("row %d time = %d, want %d", i, got.Times[i], want.Times[i])
+		}
+		for j := range want.Rows[i] {
+			if math.Abs(got.Rows[i][j]-want.Rows[i][j]) > 1e-9 {
+				t.Errorf("row %d = %v, want %v", i, got.Rows[i], want.Rows[i])
+				break
+			}
+		}
+	}
+}
+
+func TestSteps(t *testing.T) {
+	tests := []struct {
+		name string
+		step Step
+		want Frame
+	}{
+		{"lag 0", Lag{N: 0}, testFrame()},
+		{"lag 1", Lag{N: 1}, Frame{
+			Names: []string{"a", "a|lag1", "b", "b|lag1"},
+			Times: []int64{11, 12, 13, 14},
+			Rows:  [][]float64{{2, 1, 5, 5}, {4, 2, 5, 5}, {7, 4, 5, 5}, {11, 7, 5, 5}},
+		}},
+		{"lag 2", Lag{N: 2}, Frame{
+			Names: []string{"a", "a|lag1", "a|lag2", "b", "b|lag1", "b|lag2"},
+			Times: []int64{12, 13, 14},
+			Rows:  [][]float64{{4, 2, 1, 5, 5, 5}, {7, 4, 2, 5, 5, 5}, {11, 7, 4, 5, 5, 5}},
+		}},
+		{"diff 0", Diff{N: 0}, testFrame()},
+		{"diff 1", Diff{N: 1}, Frame{
+			Names: []string{"a", "b"},
+			Times: []int64{11, 12, 13, 14},
+			Rows:  [][]float64{{1, 0}, {2, 0}, {3, 0}, {4, 0}},
+		}},
+		{"diff 2", Diff{N: 2}, Frame{
+			Names: []string{"a", "b"},
+			Times: []int64{12, 13, 14},
+			Rows:  [][]float64{{1, 0}, {1, 0}, {1, 0}},
+		}},
+		{"diff 3", Diff{N: 3}, Frame{
+			Names: []string{"a", "b"},
+			Times: []int64{13, 14},
+			Rows:  [][]float64{{0, 0}, {0, 0}},
+		}},
+		{"diff longer than frame", Diff{N: 5}, Frame{Names: []string{"a", "b"}}},
+		{"smooth mean", Smoothing{Window: 2, Method: Mean}, Frame{
+			Names: []string{"a", "b"},
+			Times: []int64{10, 11, 12, 13, 14},
+			Rows:  [][]float64{{1, 5}, {1.5, 5}, {3, 5}, {5.5, 5}, {9, 5}},
+		}},
+		{"smooth ewma", Smoothing{Window: 3, Method: EWMA}, Frame{
+			Names: []string{"a", "b"},
+			Times: []int64{10, 11, 12, 13, 14},
+			Rows:  [][]float64{{1, 5}, {1.5, 5}, {2.75, 5}, {4.875, 5}, {7.9375, 5}},
+		}},
+		{"smooth window 1", Smoothing{Window: 1, Method: Median}, testFrame()},
+		{"rolling max and std", RollingStats{Window: 2, Stats: []string{"max", "std"}}, Frame{
+			Names: []string{"a", "a|max2", "a|std2", "b", "b|max2", "b|std2"},
+			Times: []int64{10, 11, 12, 13, 14},
+			Rows: [][]float64{
+				{1, 1, 0, 5, 5, 0},
+				{2, 2, 0.5, 5, 5, 0},
+				{4, 4, 1, 5, 5, 0},
+				{7, 7, 1.5, 5, 5, 0},
+				{11, 11, 2, 5, 5, 0},
+			},
+		}},
+		{"rolling mean and min", RollingStats{Window: 3, Stats: []string{"mean", "min"}}, Frame{
+			Names: []string{"a", "a|mean3", "a|min3", "b", "b|mean3", "b|min3"},
+			Times: []int64{10, 11, 12, 13, 14},
+			Rows: [][]float64{
+				{1, 1, 1, 5, 5, 5},
+				{2, 1.5, 1, 5, 5, 5},
+				{4, 7.0 / 3, 1, 5, 5, 5},
+				{7, 13.0 / 3, 2, 5, 5, 5},
+				{11, 22.0 / 3, 4, 5, 5, 5},
+			},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := testFrame()
+			got, err := tt.step.Apply(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFrame(t, got, tt.want)
+			checkFrame(t, in, testFrame())
+
+			rows, cols := tt.step.Shape(len(in.Rows), len(in.Names))
+			if rows < 0 {
+				rows = 0
+			}
+			if rows != len(got.Rows) || cols != len(got.Names) {
+				t.Errorf("Shape = %d x %d, Apply gave %d x %d", rows, cols, len(got.Rows), len(got.Names))
+			}
+		})
+	}
+}
+
+func TestStepErrors(t *testing.T) {
+	for name, step := range map[string]Step{
+		"negative lag":     Lag{N: -1},
+		"negative diff":    Diff{N: -1},
+		"unknown smooth":   Smoothing{Window: 2, Method: "mode"},
+		"negative smooth":  Smoothing{Window: -1, Method: Mean},
+		"zero window":      RollingStats{Window: 0, Stats: []string{"mean"}},
+		"unknown stat":     RollingStats{Window: 2, Stats: []string{"median"}},
+		"scale before fit": &Scale{Method: Standardize},
+	} {
+		if _, err := step.Apply(testFrame()); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestScale(t *testing.T) {
+	tests := []struct {
+		method ScaleMethod
+		want   [][]float64
+	}{
+		// a has mean 5 and std sqrt(13.2), b is constant so only shifted
+		{Standardize, [][]float64{
+			{-4 / math.Sqrt(13.2), 0},
+			{-3 / math.Sqrt(13.2), 0},
+			{-1 / math.Sqrt(13.2), 0},
+			{2 / math.Sqrt(13.2), 0},
+			{6 / math.Sqrt(13.2), 0},
+		}},
+		{MinMax, [][]float64{{0, 0}, {0.1, 0}, {0.3, 0}, {0.6, 0}, {1, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.method), func(t *testing.T) {
+			s := &Scale{Method: tt.method}
+			if err := s.Fit(testFrame()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.Apply(testFrame())
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkFrame(t, got, Frame{Names: []string{"a", "b"}, Times: []int64{10, 11, 12, 13, 14}, Rows: tt.want})
+
+			// New data is scaled with what was learned in Fit
+			got, err = s.Apply(Frame{Names: []string{"a", "b"}, Times: []int64{15}, Rows: [][]float64{{5, 6}}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.method == MinMax && (got.Rows[0][0] != 0.4 || got.Rows[0][1] != 1) {
+				t.Errorf("new row scaled to %v, want [0.4 1]", got.Rows[0])
+			}
+		})
+	}
+
+	s := &Scale{Method: "log"}
+	if err := s.Fit(testFrame()); err == nil {
+		t.Error("unknown method: got no error")
+	}
+}
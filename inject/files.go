@@ -0,0 +1,96 @@
+package inject
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/andrewm4894/learn-go/features"
+)
+
+// Label is the ground truth for one row
+type Label struct {
+	Time    int64
+	Anomaly bool
+	Kind    Kind
+}
+
+// Labels gives every time a label, anomalous if it is in one of events
+func Labels(times []int64, events []Event) []Label {
+	labels := make([]Label, len(times))
+	for i, t := range times {
+		labels[i].Time = t
+		for _, e := range events {
+			if t >= e.Start && t <= e.End {
+				labels[i].Anomaly = true
+				labels[i].Kind = e.Kind
+				break
+			}
+		}
+	}
+	return labels
+}
+
+// WriteCSV writes f as csv with a time column of unix seconds, which
+// netdata.Replay can read back
+func WriteCSV(w io.Writer, f features.Frame) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"time"}, f.Names...)); err != nil {
+		return err
+	}
+	for t, row := range f.Rows {
+		record := make([]string, len(row)+1)
+		record[0] = strconv.FormatInt(f.Times[t], 10)
+		for j, v := range row {
+			record[j+1] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteLabels writes labels as csv, "time,anomaly,kind" with anomaly 0 or 1
+func WriteLabels(w io.Writer, labels []Label) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"time", "anomaly", "kind"}); err != nil {
+		return err
+	}
+	for _, l := range labels {
+		anomaly := "0"
+		if l.Anomaly {
+			anomaly = "1"
+		}
+		if err := cw.Write([]string{strconv.FormatInt(l.Time, 10), anomaly, string(l.Kind)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ReadLabels reads labels written by WriteLabels
+func ReadLabels(r io.Reader) ([]Label, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("inject: empty labels file")
+	}
+	labels := make([]Label, 0, len(records)-1)
+	for i, record := range records[1:] {
+		if len(record) != 3 {
+			return nil, fmt.Errorf("inject: labels row %d has %d fields, want 3", i+1, len(record))
+		}
+		t, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("inject: labels row %d: %w", i+1, err)
+		}
+		labels = append(labels, Label{Time: t, Anomaly: record[1] == "1", Kind: Kind(record[2])})
+	}
+	return labels, nil
+}
@@ -0,0 +1,214 @@
+// Package inject puts labelled anomalies into time series, so detectors can
+// be scored against a known ground truth
+package inject
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/andrewm4894/learn-go/features"
+)
+
+// Kind is a kind of anomaly
+type Kind string
+
+// Kinds of anomaly. Sizes are in standard deviations of the column.
+const (
+	// Spike adds Size to each row
+	Spike Kind = "spike"
+
+	// LevelShift adds Size to each row from the start to the end of the
+	// series, only the first Length rows are labelled as after that it is
+	// the new normal
+	LevelShift Kind = "levelShift"
+
+	// TrendChange adds a ramp from 0 up to Size
+	TrendChange Kind = "trendChange"
+
+	// VarianceChange scales how far each row is from the column mean by
+	// Size, which is a plain multiplier for this kind
+	VarianceChange Kind = "varianceChange"
+
+	// Flatline holds the value from the first row
+	Flatline Kind = "flatline"
+
+	// Dropout sets the rows to NaN, like a collector that stopped reporting
+	Dropout Kind = "dropout"
+)
+
+// Kinds lists every kind of anomaly
+var Kinds = []Kind{Spike, LevelShift, TrendChange, VarianceChange, Flatline, Dropout}
+
+// Valid reports whether k is a known kind
+func (k Kind) Valid() bool {
+	for _, kind := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Injection is one anomaly to put into a frame, over rows Start to
+// Start+Length-1 of column Column, or every column if Column is -1
+type Injection struct {
+	Kind   Kind
+	Column int
+	Start  int
+	Length int
+	Size   float64
+}
+
+// Event is an injected anomaly as it ended up in the data, Start and End
+// are the times of its first and last rows
+type Event struct {
+	Kind    Kind     `json:"kind"`
+	Columns []string `json:"columns"`
+	Start   int64    `json:"start"`
+	End     int64    `json:"end"`
+}
+
+// Apply returns a copy of f with the injections made, and the events they
+// make up in time order
+func Apply(f features.Frame, injections []Injection) (features.Frame, []Event, error) {
+	out := features.Frame{Names: f.Names, Times: f.Times, Rows: make([][]float64, len(f.Rows))}
+	for t, row := range f.Rows {
+		out.Rows[t] = append([]float64(nil), row...)
+	}
+	means, sds := make([]float64, len(f.Names)), make([]float64, len(f.Names))
+	for j := range f.Names {
+		means[j], sds[j] = meanSD(f.Col(j))
+	}
+
+	var events []Event
+	for _, in := range injections {
+		if !in.Kind.Valid() {
+			return features.Frame{}, nil, fmt.Errorf("inject: unknown kind %q", in.Kind)
+		}
+		if in.Length < 1 || in.Start < 0 || in.Start+in.Length > len(f.Rows) {
+			return features.Frame{}, nil, fmt.Errorf("inject: rows %d to %d are outside the %d rows", in.Start, in.Start+in.Length-1, len(f.Rows))
+		}
+		if in.Column < -1 || in.Column >= len(f.Names) {
+			return features.Frame{}, nil, fmt.Errorf("inject: no column %d", in.Column)
+		}
+		cols := []int{in.Column}
+		if in.Column == -1 {
+			cols = make([]int, len(f.Names))
+			for j := range cols {
+				cols[j] = j
+			}
+		}
+
+		event := Event{Kind: in.Kind, Start: f.Times[in.Start], End: f.Times[in.Start+in.Length-1]}
+		for _, j := range cols {
+			event.Columns = append(event.Columns, f.Names[j])
+			first := out.Rows[in.Start][j]
+			length := in.Length
+			if in.Kind == LevelShift {
+				length = len(f.Rows) - in.Start
+			}
+			for i := 0; i < length; i++ {
+				v := &out.Rows[in.Start+i][j]
+				switch in.Kind {
+				case Spike, LevelShift:
+					*v += in.Size * sds[j]
+				case TrendChange:
+					*v += in.Size * sds[j] * float64(i+1) / float64(in.Length)
+				case VarianceChange:
+					*v = means[j] + (*v-means[j])*in.Size
+				case Flatline:
+					*v = first
+				case Dropout:
+					*v = math.NaN()
+				}
+			}
+		}
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(a, b int) bool { return events[a].Start < events[b].Start })
+	return out, events, nil
+}
+
+// Random picks n injections for a frame of rows by cols, of the given kinds
+// or any kind if there are none, and not overlapping each other, with at
+// least gap rows between them. Spikes are a single row, the rest up to
+// maxLength rows.
+func Random(rng *rand.Rand, rows, cols, n int, kinds []Kind, maxLength, gap int) ([]Injection, error) {
+	if n > 0 && (rows < 1 || cols < 1) {
+		return nil, fmt.Errorf("inject: no room for anomalies in %d rows by %d columns", rows, cols)
+	}
+	if len(kinds) == 0 {
+		kinds = Kinds
+	}
+	for _, kind := range kinds {
+		if !kind.Valid() {
+			return nil, fmt.Errorf("inject: unknown kind %q", kind)
+		}
+	}
+	if maxLength < 1 {
+		maxLength = 1
+	}
+	var injections []Injection
+	taken := make([]bool, rows)
+	for len(injections) < n {
+		placed := false
+		for try := 0; try < 100 && !placed; try++ {
+			in := Injection{Kind: kinds[rng.Intn(len(kinds))], Column: rng.Intn(cols), Length: 1}
+			if in.Kind != Spike {
+				in.Length = 1 + rng.Intn(maxLength)
+			}
+			if in.Length > rows {
+				continue
+			}
+			in.Start = rng.Intn(rows - in.Length + 1)
+			if !free(taken, in.Start-gap, in.Start+in.Length+gap) {
+				continue
+			}
+			sign := 1.0
+			if rng.Intn(2) == 0 {
+				sign = -1
+			}
+			switch in.Kind {
+			case Spike:
+				in.Size = sign * (4 + 4*rng.Float64())
+			case LevelShift, TrendChange:
+				in.Size = sign * (3 + 3*rng.Float64())
+			case VarianceChange:
+				in.Size = 3 + 3*rng.Float64()
+			}
+			for i := in.Start; i < in.Start+in.Length; i++ {
+				taken[i] = true
+			}
+			injections = append(injections, in)
+			placed = true
+		}
+		if !placed {
+			return nil, fmt.Errorf("inject: no room for %d anomalies in %d rows", n, rows)
+		}
+	}
+	return injections, nil
+}
+
+// Whether no row from start up to end is taken
+func free(taken []bool, start, end int) bool {
+	for i := start; i < end; i++ {
+		if i >= 0 && i < len(taken) && taken[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func meanSD(x []float64) (float64, float64) {
+	var mean, sd float64
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+	for _, v := range x {
+		sd += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sd / float64(len(x)))
+}
@@ -0,0 +1,143 @@
+package inject
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/andrewm4894/learn-go/features"
+)
+
+// Ten rows of two columns, a alternates 0 and 2 so has mean 1 and sd 1, b
+// is constant
+func testFrame() features.Frame {
+	f := features.Frame{Names: []string{"a", "b"}}
+	for i := 0; i < 10; i++ {
+		f.Times = append(f.Times, int64(100+i))
+		f.Rows = append(f.Rows, []float64{float64(2 * (i % 2)), 5})
+	}
+	return f
+}
+
+func TestApply(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		in   Injection
+		want []float64 // column a after injecting
+	}{
+		{Injection{Kind: Spike, Column: 0, Start: 3, Length: 1, Size: 4}, []float64{0, 2, 0, 6, 0, 2, 0, 2, 0, 2}},
+		{Injection{Kind: LevelShift, Column: 0, Start: 3, Length: 2, Size: -3}, []float64{0, 2, 0, -1, -3, -1, -3, -1, -3, -1}},
+		{Injection{Kind: TrendChange, Column: 0, Start: 2, Length: 4, Size: 4}, []float64{0, 2, 1, 4, 3, 6, 0, 2, 0, 2}},
+		{Injection{Kind: VarianceChange, Column: 0, Start: 4, Length: 3, Size: 3}, []float64{0, 2, 0, 2, -2, 4, -2, 2, 0, 2}},
+		{Injection{Kind: Flatline, Column: 0, Start: 5, Length: 3}, []float64{0, 2, 0, 2, 0, 2, 2, 2, 0, 2}},
+		{Injection{Kind: Dropout, Column: 0, Start: 6, Length: 2}, []float64{0, 2, 0, 2, 0, 2, nan, nan, 0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.in.Kind), func(t *testing.T) {
+			in := testFrame()
+			out, events, err := Apply(in, []Injection{tt.in})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.want {
+				got := out.Rows[i][0]
+				if got != want && !(math.IsNaN(got) && math.IsNaN(want)) {
+					t.Errorf("a = %v, want %v", out.Col(0), tt.want)
+					break
+				}
+				if out.Rows[i][1] != 5 {
+					t.Errorf("b changed to %v", out.Col(1))
+					break
+				}
+			}
+			if in.Rows[tt.in.Start][0] != testFrame().Rows[tt.in.Start][0] {
+				t.Error("Apply changed its input")
+			}
+
+			// Only the injected rows are labelled, even for a level shift
+			// that carries on past them
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			e := events[0]
+			start, end := int64(100+tt.in.Start), int64(100+tt.in.Start+tt.in.Length-1)
+			if e.Kind != tt.in.Kind || e.Start != start || e.End != end || len(e.Columns) != 1 || e.Columns[0] != "a" {
+				t.Errorf("event = %+v, want %v on [a] from %v to %v", e, tt.in.Kind, start, end)
+			}
+			for _, l := range Labels(out.Times, events) {
+				anomaly := l.Time >= start && l.Time <= end
+				if l.Anomaly != anomaly || (anomaly && l.Kind != tt.in.Kind) {
+					t.Errorf("label at %v = %+v, want anomaly %v", l.Time, l, anomaly)
+				}
+			}
+		})
+	}
+}
+
+func TestApplyAllColumns(t *testing.T) {
+	out, events, err := Apply(testFrame(), []Injection{{Kind: Spike, Column: -1, Start: 0, Length: 1, Size: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// b has sd 0 so a spike in sds leaves it alone
+	if out.Rows[0][0] != 2 || out.Rows[0][1] != 5 {
+		t.Errorf("first row = %v, want [2 5]", out.Rows[0])
+	}
+	if len(events[0].Columns) != 2 {
+		t.Errorf("event columns = %v, want [a b]", events[0].Columns)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	for name, in := range map[string]Injection{
+		"unknown kind": {Kind: "wobble", Column: 0, Start: 0, Length: 1},
+		"no rows":      {Kind: Spike, Column: 0, Start: 0, Length: 0},
+		"past the end": {Kind: Spike, Column: 0, Start: 9, Length: 2},
+		"before start": {Kind: Spike, Column: 0, Start: -1, Length: 1},
+		"no column":    {Kind: Spike, Column: 2, Start: 0, Length: 1},
+		"column below": {Kind: Spike, Column: -2, Start: 0, Length: 1},
+	} {
+		if _, _, err := Apply(testFrame(), []Injection{in}); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	injections, err := Random(rng, 1000, 3, 10, nil, 20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(injections) != 10 {
+		t.Fatalf("got %d injections, want 10", len(injections))
+	}
+	taken := make([]bool, 1000)
+	for _, in := range injections {
+		if !in.Kind.Valid() || in.Column < 0 || in.Column >= 3 || in.Length < 1 || in.Length > 20 {
+			t.Errorf("bad injection %+v", in)
+		}
+		if in.Kind == Spike && in.Length != 1 {
+			t.Errorf("spike of %d rows", in.Length)
+		}
+		if !free(taken, in.Start-10, in.Start+in.Length+10) {
+			t.Errorf("%+v is within 10 rows of another", in)
+		}
+		for i := in.Start; i < in.Start+in.Length; i++ {
+			taken[i] = true
+		}
+	}
+	for name, args := range map[string]struct {
+		rows, cols int
+		kinds      []Kind
+	}{
+		"no columns":   {1000, 0, nil},
+		"no rows":      {0, 3, nil},
+		"unknown kind": {1000, 3, []Kind{Spike, "wobble"}},
+		"no room":      {20, 3, nil},
+	} {
+		if _, err := Random(rng, args.rows, args.cols, 10, args.kinds, 20, 10); err == nil {
+			t.Errorf("%s: got no error", name)
+		}
+	}
+}
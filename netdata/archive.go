@@ -0,0 +1,199 @@
+package netdata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Record is one fetch saved in an archive
+type Record struct {
+	FetchedAt time.Time `json:"fetchedAt"`
+	Host      string    `json:"host"`
+	Chart     string    `json:"chart"`
+	URL       string    `json:"url"`
+	Status    int       `json:"status"`
+	Body      string    `json:"body,omitempty"`
+	Err       string    `json:"err,omitempty"`
+}
+
+// Recorder appends fetch results to an archive file of gzipped json lines.
+// Every record is written as a gzip stream of its own onto the end of the
+// file, so earlier records are never rewritten. A record cut short by a
+// crash is skipped when the archive is read, along with nothing else.
+type Recorder struct {
+	mu sync.Mutex
+	f  *os.File
+	gz *gzip.Writer
+}
+
+// NewRecorder opens the archive at path for appending, creating it if need be
+func NewRecorder(path string) (*Recorder, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{f: f, gz: gzip.NewWriter(f)}, nil
+}
+
+// Record saves res, fetched at fetchedAt
+func (r *Recorder) Record(res Result, fetchedAt time.Time) error {
+	req := res.Job.Request
+	if req.Format == "" {
+		req.Format = "json"
+	}
+	rec := Record{
+		FetchedAt: fetchedAt,
+		Host:      res.Job.Client.Host(),
+		Chart:     req.Chart,
+		URL:       res.Job.Client.DataURL(req),
+		Status:    res.Status,
+		Body:      string(res.Body),
+	}
+	if res.Err != nil {
+		rec.Err = res.Err.Error()
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.gz.Reset(r.f)
+	if _, err := r.gz.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	return r.gz.Close()
+}
+
+// Close closes the archive file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.f.Close()
+}
+
+// ReadArchive reads every record in the archive at path, oldest first.
+// Records that can't be read, such as one cut short by a crash, are skipped
+// and reading carries on from the next gzip stream in the file. An empty
+// archive has no records.
+func ReadArchive(path string) ([]Record, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	for off := 0; off < len(b); {
+		recs, n, err := readMember(b[off:])
+		if err != nil {
+			// Look for the start of the next stream after the bad one
+			next := bytes.Index(b[off+1:], gzipMagic)
+			if next < 0 {
+				break
+			}
+			off += 1 + next
+			continue
+		}
+		records = append(records, recs...)
+		off += n
+	}
+	return records, nil
+}
+
+// The first bytes of a gzip stream, magic number and deflate method
+var gzipMagic = []byte{0x1f, 0x8b, 8}
+
+// Read the records in the gzip stream at the start of b, and how many bytes
+// the stream took up
+func readMember(b []byte) ([]Record, int, error) {
+	br := bytes.NewReader(b)
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, 0, err
+	}
+	gz.Multistream(false)
+	body, err := ioutil.ReadAll(gz)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []Record
+	dec := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var rec Record
+		err := dec.Decode(&rec)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		records = append(records, rec)
+	}
+	return records, len(b) - br.Len(), nil
+}
+
+// ArchiveResponses merges the successful json records in an archive into
+// one response per chart, keyed "host|chart", with each timestamp once and
+// oldest first. Records whose labels differ from the chart's first are left
+// out, as their columns would not line up.
+func ArchiveResponses(records []Record) map[string]*Response {
+	type merged struct {
+		labels []string
+		rows   map[float64][]float64
+	}
+	byKey := make(map[string]*merged)
+	for _, rec := range records {
+		if rec.Err != "" || rec.Status != 200 {
+			continue
+		}
+		var resp Response
+		if err := json.Unmarshal([]byte(rec.Body), &resp); err != nil {
+			continue
+		}
+		key := rec.Host + "|" + rec.Chart
+		m, ok := byKey[key]
+		if !ok {
+			m = &merged{labels: resp.Labels, rows: make(map[float64][]float64)}
+			byKey[key] = m
+		}
+		if !equalLabels(m.labels, resp.Labels) {
+			continue
+		}
+		for _, row := range resp.Data {
+			m.rows[row[0]] = row
+		}
+	}
+
+	responses := make(map[string]*Response, len(byKey))
+	for key, m := range byKey {
+		resp := &Response{Labels: m.labels}
+		for _, row := range m.rows {
+			resp.Data = append(resp.Data, row)
+		}
+		sort.Slice(resp.Data, func(i, j int) bool { return resp.Data[i][0] < resp.Data[j][0] })
+		responses[key] = resp
+	}
+	return responses
+}
+
+func equalLabels(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package netdata
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Record one response for chart with a row at each of times
+func record(t *testing.T, path, chart string, times ...float64) {
+	t.Helper()
+	resp := Response{Labels: []string{"time", "a"}}
+	for _, ts := range times {
+		resp.Data = append(resp.Data, []float64{ts, 1})
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := Result{Job: Job{Client: NewClient("example"), Request: DataRequest{Chart: chart}}, Status: 200, Body: body}
+	if err := rec.Record(res, time.Unix(int64(times[0]), 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadArchiveSkipsTruncatedRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl.gz")
+	record(t, path, "system.cpu", 100, 101)
+
+	// Cut the second record short, as a crash would, then carry on recording
+	record(t, path, "system.cpu", 102)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(path, info.Size()-10); err != nil {
+		t.Fatal(err)
+	}
+	record(t, path, "system.cpu", 103)
+
+	records, err := ReadArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if got := string(records[1].Body); got != `{"labels":["time","a"],"data":[[103,1]]}` {
+		t.Errorf("second record body = %s", got)
+	}
+
+	// Replay uses the records either side of the bad one
+	r, err := NewReplay(path, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Start != 100 {
+		t.Errorf("replay starts at %d, want 100", r.Start)
+	}
+	if n := len(r.cache["example|system.cpu"].Data); n != 3 {
+		t.Errorf("replay has %d rows, want 3", n)
+	}
+}
+
+func TestReadArchiveEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "archive.jsonl.gz")
+	rec, err := NewRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.Close()
+
+	records, err := ReadArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 0 {
+		t.Errorf("got %d records, want 0", len(records))
+	}
+}
@@ -0,0 +1,138 @@
+package netdata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+)
+
+// Dimension is a single dimension of a chart
+type Dimension struct {
+	Name string `json:"name"`
+}
+
+// Chart describes one chart as listed by /api/v1/charts
+type Chart struct {
+	ID          string               `json:"id"`
+	Name        string               `json:"name"`
+	Type        string               `json:"type"`
+	Family      string               `json:"family"`
+	Context     string               `json:"context"`
+	Title       string               `json:"title"`
+	Units       string               `json:"units"`
+	UpdateEvery int                  `json:"update_every"`
+	Enabled     bool                 `json:"enabled"`
+	Dimensions  map[string]Dimension `json:"dimensions"`
+}
+
+// ChartsResponse is used to unmarshal json from the /api/v1/charts endpoint
+type ChartsResponse struct {
+	Hostname    string           `json:"hostname"`
+	Version     string           `json:"version"`
+	UpdateEvery int              `json:"update_every"`
+	ChartsCount int              `json:"charts_count"`
+	Charts      map[string]Chart `json:"charts"`
+}
+
+// Charts lists all the charts on the host
+func (c *Client) Charts(ctx context.Context) (*ChartsResponse, error) {
+	bodyBytes, err := c.fetch(ctx, c.BaseURL+"/api/v1/charts")
+	if err != nil {
+		return nil, err
+	}
+
+	var charts ChartsResponse
+	if err := json.Unmarshal(bodyBytes, &charts); err != nil {
+		return nil, fmt.Errorf("netdata: decoding charts: %w", err)
+	}
+	return &charts, nil
+}
+
+// DiscoverCharts lists the charts on the host and returns the ones that
+// match filter, sorted by id
+func (c *Client) DiscoverCharts(ctx context.Context, filter ChartFilter) ([]Chart, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	charts, err := c.Charts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Select(charts.Charts), nil
+}
+
+// Globs is a set of include and exclude glob patterns, as understood by
+// path.Match. A value matches if it matches any include pattern (or there
+// are none) and no exclude pattern.
+type Globs struct {
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// Match reports whether value passes the include and exclude patterns
+func (g Globs) Match(value string) bool {
+	for _, pattern := range g.Exclude {
+		if ok, _ := path.Match(pattern, value); ok {
+			return false
+		}
+	}
+	if len(g.Include) == 0 {
+		return true
+	}
+	for _, pattern := range g.Include {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (g Globs) validate() error {
+	for _, pattern := range append(append([]string{}, g.Include...), g.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("netdata: bad pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// ChartFilter picks charts by id, family, context and type. A chart has to
+// pass every one of them to be selected.
+type ChartFilter struct {
+	IDs      Globs `json:"ids"`
+	Families Globs `json:"families"`
+	Contexts Globs `json:"contexts"`
+	Types    Globs `json:"types"`
+}
+
+// Validate checks all the patterns are well formed
+func (f ChartFilter) Validate() error {
+	for _, g := range []Globs{f.IDs, f.Families, f.Contexts, f.Types} {
+		if err := g.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match reports whether chart passes the filter
+func (f ChartFilter) Match(chart Chart) bool {
+	return f.IDs.Match(chart.ID) &&
+		f.Families.Match(chart.Family) &&
+		f.Contexts.Match(chart.Context) &&
+		f.Types.Match(chart.Type)
+}
+
+// Select returns the charts that pass the filter, sorted by id
+func (f ChartFilter) Select(charts map[string]Chart) []Chart {
+	var selected []Chart
+	for _, chart := range charts {
+		if f.Match(chart) {
+			selected = append(selected, chart)
+		}
+	}
+	sort.Slice(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+	return selected
+}
@@ -0,0 +1,196 @@
+// Package netdata is a small client for the Netdata REST api
+package netdata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Response is used to unmarshal json from the /api/v1/data endpoint
+type Response struct {
+	Labels []string    `json:"labels"`
+	Data   [][]float64 `json:"data"`
+}
+
+// DataRequest holds the query params for the /api/v1/data endpoint.
+// Zero values are left out of the query so netdata uses its own defaults.
+type DataRequest struct {
+	Chart      string
+	After      int64
+	Before     int64
+	Points     int
+	Group      string
+	GTime      int
+	Format     string
+	Options    []string
+	Dimensions []string
+}
+
+// Values encodes the request as url query params
+func (r DataRequest) Values() url.Values {
+	v := url.Values{}
+	v.Set("chart", r.Chart)
+	if r.After != 0 {
+		v.Set("after", strconv.FormatInt(r.After, 10))
+	}
+	if r.Before != 0 {
+		v.Set("before", strconv.FormatInt(r.Before, 10))
+	}
+	if r.Points != 0 {
+		v.Set("points", strconv.Itoa(r.Points))
+	}
+	if r.Group != "" {
+		v.Set("group", r.Group)
+	}
+	if r.GTime != 0 {
+		v.Set("gtime", strconv.Itoa(r.GTime))
+	}
+	if r.Format != "" {
+		v.Set("format", r.Format)
+	}
+	if len(r.Options) > 0 {
+		v.Set("options", strings.Join(r.Options, "|"))
+	}
+	if len(r.Dimensions) > 0 {
+		v.Set("dimensions", strings.Join(r.Dimensions, "|"))
+	}
+	return v
+}
+
+// StatusError is returned when netdata answers with a non 200 status
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("netdata: %s returned status %d", e.URL, e.StatusCode)
+}
+
+// Client talks to a single netdata host
+type Client struct {
+	// BaseURL is the scheme and host, e.g. "https://london.my-netdata.io"
+	BaseURL string
+
+	// HTTPClient is used for all requests, http.DefaultClient if nil
+	HTTPClient *http.Client
+
+	// Timeout bounds each single request, no limit beyond ctx if zero
+	Timeout time.Duration
+
+	// Retry is the retry policy for this host, requests are only tried once if nil
+	Retry *RetryPolicy
+}
+
+// NewClient makes a client for host. A bare host such as
+// "london.my-netdata.io" is assumed to be https.
+func NewClient(host string) *Client {
+	base := host
+	if !strings.Contains(base, "://") {
+		base = "https://" + base
+	}
+	return &Client{BaseURL: strings.TrimSuffix(base, "/")}
+}
+
+// Host returns the host part of the client's BaseURL
+func (c *Client) Host() string {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return c.BaseURL
+	}
+	return u.Host
+}
+
+// DataURL builds the full /api/v1/data url for req
+func (c *Client) DataURL(req DataRequest) string {
+	return c.BaseURL + "/api/v1/data?" + req.Values().Encode()
+}
+
+// Get fetches the raw body for req in whatever format it asks for
+func (c *Client) Get(ctx context.Context, req DataRequest) ([]byte, error) {
+	if req.Chart == "" {
+		return nil, fmt.Errorf("netdata: no chart in request")
+	}
+	return c.fetch(ctx, c.DataURL(req))
+}
+
+// Get url u, retrying as per the client's retry policy
+func (c *Client) fetch(ctx context.Context, u string) ([]byte, error) {
+	if c.Retry == nil {
+		return c.get(ctx, u)
+	}
+
+	var bodyBytes []byte
+	err := c.Retry.Run(ctx, func() error {
+		var err error
+		bodyBytes, err = c.get(ctx, u)
+		return err
+	})
+	return bodyBytes, err
+}
+
+// Data fetches req as json and unmarshals it into a Response
+func (c *Client) Data(ctx context.Context, req DataRequest) (*Response, error) {
+	req.Format = "json"
+	bodyBytes, err := c.Get(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return decode(req.Chart, bodyBytes)
+}
+
+func decode(chart string, bodyBytes []byte) (*Response, error) {
+	var data Response
+	if err := json.Unmarshal(bodyBytes, &data); err != nil {
+		return nil, fmt.Errorf("netdata: decoding %s: %w", chart, err)
+	}
+	return &data, nil
+}
+
+func (c *Client) get(ctx context.Context, u string) ([]byte, error) {
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	// Apply the per request timeout on top of whatever deadline ctx has
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: u, StatusCode: resp.StatusCode}
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// IsTimeout reports whether err came from a deadline being hit, either on
+// the context or in the underlying connection
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,169 @@
+package netdata
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// Job is a single fetch for a Pool to run
+type Job struct {
+	Client  *Client
+	Request DataRequest
+}
+
+// Key identifies the job as "host|chart"
+func (j Job) Key() string {
+	return j.Client.Host() + "|" + j.Request.Chart
+}
+
+// Result is sent back for every Job, Err is set if the fetch failed
+type Result struct {
+	Job    Job
+	Status int // http status, 0 if we never got a response
+	Body   []byte
+	Data   *Response // only set for json requests
+	Err    error
+}
+
+// PoolOptions configures a Pool
+type PoolOptions struct {
+	// Workers is how many fetches can run at once, defaults to 4
+	Workers int `json:"workers"`
+
+	// PerHost caps concurrent fetches against any one host, no cap if zero
+	PerHost int `json:"perHost"`
+
+	// QueueSize is how many jobs can wait for a worker before Submit blocks
+	QueueSize int `json:"queueSize"`
+}
+
+// Pool runs fetches on a fixed number of workers
+type Pool struct {
+	opts  PoolOptions
+	queue chan task
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+
+	wg sync.WaitGroup
+}
+
+type task struct {
+	ctx  context.Context
+	job  Job
+	done func(Result)
+}
+
+// NewPool starts the workers for a pool, call Close when finished with it
+func NewPool(opts PoolOptions) *Pool {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	p := &Pool{
+		opts:  opts,
+		queue: make(chan task, opts.QueueSize),
+		hosts: make(map[string]chan struct{}),
+	}
+	for i := 0; i < opts.Workers; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+	return p
+}
+
+// Close stops the workers once the queue is drained
+func (p *Pool) Close() {
+	close(p.queue)
+	p.wg.Wait()
+}
+
+// Submit queues job, calling done with its result once it has run. It
+// blocks while the queue is full and gives up if ctx is done first.
+func (p *Pool) Submit(ctx context.Context, job Job, done func(Result)) error {
+	select {
+	case p.queue <- task{ctx: ctx, job: job, done: done}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Fetch runs jobs through the pool and waits for them all, returning one
+// result per job in the same order as jobs
+func (p *Pool) Fetch(ctx context.Context, jobs []Job) []Result {
+	results := make([]Result, len(jobs))
+
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		i := i
+		wg.Add(1)
+		err := p.Submit(ctx, job, func(r Result) {
+			results[i] = r
+			wg.Done()
+		})
+		if err != nil {
+			results[i] = Result{Job: job, Err: err}
+			wg.Done()
+		}
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		t.done(p.run(t.ctx, t.job))
+	}
+}
+
+func (p *Pool) run(ctx context.Context, job Job) Result {
+	result := Result{Job: job}
+
+	// Wait for a slot on this job's host
+	if sem := p.hostSem(job.Client.Host()); sem != nil {
+		select {
+		case sem <- struct{}{}:
+			defer func() { <-sem }()
+		case <-ctx.Done():
+			result.Err = ctx.Err()
+			return result
+		}
+	}
+
+	req := job.Request
+	if req.Format == "" {
+		req.Format = "json"
+	}
+	result.Body, result.Err = job.Client.Get(ctx, req)
+	if result.Err != nil {
+		var statusErr *StatusError
+		if errors.As(result.Err, &statusErr) {
+			result.Status = statusErr.StatusCode
+		}
+		return result
+	}
+	result.Status = http.StatusOK
+
+	if req.Format == "json" {
+		result.Data, result.Err = decode(req.Chart, result.Body)
+	}
+	return result
+}
+
+func (p *Pool) hostSem(host string) chan struct{} {
+	if p.opts.PerHost <= 0 {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sem, ok := p.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, p.opts.PerHost)
+		p.hosts[host] = sem
+	}
+	return sem
+}
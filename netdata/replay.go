@@ -0,0 +1,394 @@
+package netdata
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source gets data for jobs, results come back in the same order as jobs.
+// A Pool gets it from the live api, a Replay from saved responses.
+type Source interface {
+	Fetch(ctx context.Context, jobs []Job) []Result
+}
+
+// Replay serves saved /api/v1/data responses as if they were coming from
+// the api live, so models can be trained and scored with no network.
+//
+// Responses are read from Dir/<host>/<chart>.json or .csv, or from
+// Dir/<chart>.json or .csv for any host. Json files are the api's json
+// format, csv files have a header row and a time column of unix seconds or
+// "2006-01-02 15:04:05" times. A Dir/<host>/charts.json saved from
+// /api/v1/charts is used for chart discovery if there is one. Dir can also
+// be an archive written by a Recorder, in which case everything comes from
+// that.
+//
+// Replay keeps its own clock, which starts at Start and runs Speed times
+// faster than real time. Relative after and before in requests are taken
+// from that clock. Points averages rows down like the api, group and gtime
+// are ignored, and result bodies are always json with missing values as
+// null.
+type Replay struct {
+	Dir   string
+	Speed float64
+	Start int64
+
+	began   time.Time
+	archive bool
+	mu      sync.Mutex
+	cache   map[string]*Response
+}
+
+// NewReplay makes a replay of the responses in dir. The clock starts at
+// start, or if that is 0 at warmup seconds after the first row of any
+// response, so there is some history to train on straight away.
+func NewReplay(dir string, speed float64, start, warmup int64) (*Replay, error) {
+	r := &Replay{Dir: dir, Speed: speed, Start: start, cache: make(map[string]*Response)}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		records, err := ReadArchive(dir)
+		if err != nil {
+			return nil, err
+		}
+		r.archive = true
+		r.cache = ArchiveResponses(records)
+	}
+	if start == 0 {
+		first, err := r.firstTime()
+		if err != nil {
+			return nil, err
+		}
+		r.Start = first + warmup
+	}
+	r.began = time.Now()
+	return r, nil
+}
+
+// Now is the time on the replay's clock
+func (r *Replay) Now() int64 {
+	return r.Start + int64(r.Speed*time.Since(r.began).Seconds())
+}
+
+// Fetch implements Source
+func (r *Replay) Fetch(ctx context.Context, jobs []Job) []Result {
+	now := r.Now()
+	results := make([]Result, len(jobs))
+	for i, job := range jobs {
+		results[i] = r.run(ctx, job, now)
+	}
+	return results
+}
+
+func (r *Replay) run(ctx context.Context, job Job, now int64) Result {
+	result := Result{Job: job}
+	if err := ctx.Err(); err != nil {
+		result.Err = err
+		return result
+	}
+	saved, err := r.load(job.Client.Host(), job.Request.Chart)
+	if err != nil {
+		result.Status = http.StatusNotFound
+		result.Err = err
+		return result
+	}
+
+	// Rows in the window, newest first like the api
+	after, before := window(job.Request.After, job.Request.Before, now)
+	resp := &Response{Labels: saved.Labels}
+	for _, row := range saved.Data {
+		if t := int64(row[0]); t >= after && t <= before {
+			resp.Data = append(resp.Data, row)
+		}
+	}
+	sort.SliceStable(resp.Data, func(i, j int) bool { return resp.Data[i][0] > resp.Data[j][0] })
+	resp.Data = average(resp.Data, job.Request.Points)
+
+	result.Status = http.StatusOK
+	result.Body, result.Err = encode(resp)
+	if req := job.Request; req.Format == "" || req.Format == "json" {
+		result.Data = resp
+	}
+	return result
+}
+
+// Average rows, newest first, down to at most points rows. Each is the mean
+// of a group of rows, at the time of the newest, NaN is left out of the mean.
+func average(rows [][]float64, points int) [][]float64 {
+	if points <= 0 || len(rows) <= points {
+		return rows
+	}
+	group := (len(rows) + points - 1) / points
+	var out [][]float64
+	for start := 0; start < len(rows); start += group {
+		end := start + group
+		if end > len(rows) {
+			end = len(rows)
+		}
+		row := make([]float64, len(rows[start]))
+		row[0] = rows[start][0]
+		for j := 1; j < len(row); j++ {
+			var sum, count float64
+			for _, r := range rows[start:end] {
+				if j < len(r) && !math.IsNaN(r[j]) {
+					sum += r[j]
+					count++
+				}
+			}
+			// 0/0 is NaN when every value is missing
+			row[j] = sum / count
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+// Json for resp like the api's, json has no NaN so missing values are null
+func encode(resp *Response) ([]byte, error) {
+	data := make([][]*float64, len(resp.Data))
+	for i, row := range resp.Data {
+		data[i] = make([]*float64, len(row))
+		for j := range row {
+			if !math.IsNaN(row[j]) {
+				data[i][j] = &row[j]
+			}
+		}
+	}
+	return json.Marshal(struct {
+		Labels []string     `json:"labels"`
+		Data   [][]*float64 `json:"data"`
+	}{resp.Labels, data})
+}
+
+// Absolute after and before for a request made at now. Like the api, values
+// of zero or less are relative to now, and before defaults to now.
+func window(after, before, now int64) (int64, int64) {
+	if before <= 0 {
+		before += now
+	}
+	if after <= 0 {
+		after += before
+	}
+	return after, before
+}
+
+// Charts lists the charts that can be replayed for host, keyed by id like
+// ChartsResponse.Charts. Without a saved charts.json only ID, Name, Type and
+// Context are known, worked out from the file names.
+func (r *Replay) Charts(host string) (map[string]Chart, error) {
+	if r.archive {
+		charts := make(map[string]Chart)
+		for key := range r.cache {
+			if id := strings.TrimPrefix(key, host+"|"); id != key {
+				charts[id] = chartFromID(id)
+			}
+		}
+		if len(charts) == 0 {
+			return nil, fmt.Errorf("netdata: no saved charts for %v in %v", host, r.Dir)
+		}
+		return charts, nil
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(r.Dir, host, "charts.json")); err == nil {
+		var saved ChartsResponse
+		if err := json.Unmarshal(b, &saved); err != nil {
+			return nil, fmt.Errorf("netdata: replay charts for %v: %w", host, err)
+		}
+		return saved.Charts, nil
+	}
+
+	charts := make(map[string]Chart)
+	for _, dir := range []string{r.Dir, filepath.Join(r.Dir, host)} {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			ext := filepath.Ext(file.Name())
+			id := strings.TrimSuffix(file.Name(), ext)
+			if file.IsDir() || (ext != ".json" && ext != ".csv") || id == "charts" {
+				continue
+			}
+			charts[id] = chartFromID(id)
+		}
+	}
+	if len(charts) == 0 {
+		return nil, fmt.Errorf("netdata: no saved charts for %v in %v", host, r.Dir)
+	}
+	return charts, nil
+}
+
+// What can be worked out about a chart from just its id
+func chartFromID(id string) Chart {
+	return Chart{ID: id, Name: id, Type: strings.SplitN(id, ".", 2)[0], Context: id, Enabled: true}
+}
+
+// DiscoverCharts is Client.DiscoverCharts for saved charts
+func (r *Replay) DiscoverCharts(host string, filter ChartFilter) ([]Chart, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	charts, err := r.Charts(host)
+	if err != nil {
+		return nil, err
+	}
+	return filter.Select(charts), nil
+}
+
+// Load and cache the saved response for chart on host
+func (r *Replay) load(host, chart string) (*Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := host + "|" + chart
+	if resp, ok := r.cache[key]; ok {
+		return resp, nil
+	}
+	dirs := []string{filepath.Join(r.Dir, host), r.Dir}
+	if r.archive {
+		dirs = nil
+	}
+	for _, dir := range dirs {
+		for _, ext := range []string{".json", ".csv"} {
+			path := filepath.Join(dir, chart+ext)
+			b, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			resp, err := parseSaved(ext, b)
+			if err != nil {
+				return nil, fmt.Errorf("netdata: %v: %w", path, err)
+			}
+			r.cache[key] = resp
+			return resp, nil
+		}
+	}
+	return nil, fmt.Errorf("netdata: no saved data for %v on %v: %w", chart, host, os.ErrNotExist)
+}
+
+// ReadSaved reads a saved response, json or csv as for Replay, with rows
+// in the order they were saved
+func ReadSaved(path string) (*Response, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := parseSaved(filepath.Ext(path), b)
+	if err != nil {
+		return nil, fmt.Errorf("netdata: %v: %w", path, err)
+	}
+	return resp, nil
+}
+
+func parseSaved(ext string, b []byte) (*Response, error) {
+	if ext == ".json" {
+		var resp Response
+		if err := json.Unmarshal(b, &resp); err != nil {
+			return nil, err
+		}
+		return &resp, nil
+	}
+
+	records, err := csv.NewReader(bytes.NewReader(b)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty csv")
+	}
+	resp := &Response{Labels: records[0]}
+	for _, record := range records[1:] {
+		row := make([]float64, len(record))
+		for j, field := range record {
+			switch {
+			case j == 0:
+				row[j], err = parseTime(field)
+			case field == "" || field == "null":
+				// Missing, as netdata writes it or left empty
+				row[j] = math.NaN()
+			default:
+				row[j], err = strconv.ParseFloat(field, 64)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		resp.Data = append(resp.Data, row)
+	}
+	return resp, nil
+}
+
+// Unix seconds or a netdata csv datetime, which is in utc
+func parseTime(s string) (float64, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", s)
+	if err != nil {
+		return 0, err
+	}
+	return float64(t.Unix()), nil
+}
+
+// First row time across every saved response
+func (r *Replay) firstTime() (int64, error) {
+	var first int64
+	if r.archive {
+		for _, resp := range r.cache {
+			for _, row := range resp.Data {
+				if t := int64(row[0]); first == 0 || t < first {
+					first = t
+				}
+			}
+		}
+		if first == 0 {
+			return 0, fmt.Errorf("netdata: no saved data in %v", r.Dir)
+		}
+		return first, nil
+	}
+	err := filepath.Walk(r.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || (ext != ".json" && ext != ".csv") || info.Name() == "charts.json" {
+			return nil
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		resp, err := parseSaved(ext, b)
+		if err != nil {
+			return fmt.Errorf("netdata: %v: %w", path, err)
+		}
+		for _, row := range resp.Data {
+			if t := int64(row[0]); first == 0 || t < first {
+				first = t
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if first == 0 {
+		return 0, fmt.Errorf("netdata: no saved data in %v", r.Dir)
+	}
+	return first, nil
+}
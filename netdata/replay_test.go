@@ -0,0 +1,288 @@
+package netdata
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Write files under dir, keyed by path relative to it
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Rows are equal with NaN equal to itself
+func equalRows(a, b [][]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j, v := range a[i] {
+			if w := b[i][j]; v != w && !(math.IsNaN(v) && math.IsNaN(w)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		after, before, now int64
+		wantAfter          int64
+		wantBefore         int64
+	}{
+		{0, 0, 1000, 1000, 1000},
+		{-60, 0, 1000, 940, 1000},
+		{-60, -100, 1000, 840, 900},
+		{500, 0, 1000, 500, 1000},
+		{500, 800, 1000, 500, 800},
+		{-60, 800, 1000, 740, 800},
+	}
+	for _, tt := range tests {
+		after, before := window(tt.after, tt.before, tt.now)
+		if after != tt.wantAfter || before != tt.wantBefore {
+			t.Errorf("window(%v, %v, %v) = %v, %v, want %v, %v",
+				tt.after, tt.before, tt.now, after, before, tt.wantAfter, tt.wantBefore)
+		}
+	}
+}
+
+func TestParseSaved(t *testing.T) {
+	nan := math.NaN()
+	tests := []struct {
+		name    string
+		ext     string
+		content string
+		want    *Response
+		wantErr bool
+	}{
+		{
+			name:    "json",
+			ext:     ".json",
+			content: `{"labels":["time","a","b"],"data":[[101,1,2],[100,3,4]]}`,
+			want:    &Response{Labels: []string{"time", "a", "b"}, Data: [][]float64{{101, 1, 2}, {100, 3, 4}}},
+		},
+		{
+			name:    "csv seconds",
+			ext:     ".csv",
+			content: "time,a,b\n101,1,2\n100,3.5,4\n",
+			want:    &Response{Labels: []string{"time", "a", "b"}, Data: [][]float64{{101, 1, 2}, {100, 3.5, 4}}},
+		},
+		{
+			name:    "csv datetimes in utc",
+			ext:     ".csv",
+			content: "time,a\n2020-09-13 12:26:40,1\n",
+			want:    &Response{Labels: []string{"time", "a"}, Data: [][]float64{{1600000000, 1}}},
+		},
+		{
+			name:    "csv missing values",
+			ext:     ".csv",
+			content: "time,a,b\n101,,2\n100,3,null\n",
+			want:    &Response{Labels: []string{"time", "a", "b"}, Data: [][]float64{{101, nan, 2}, {100, 3, nan}}},
+		},
+		{name: "bad json", ext: ".json", content: `{"labels":`, wantErr: true},
+		{name: "empty csv", ext: ".csv", content: "", wantErr: true},
+		{name: "bad value", ext: ".csv", content: "time,a\n100,abc\n", wantErr: true},
+		{name: "bad time", ext: ".csv", content: "time,a\nyesterday,1\n", wantErr: true},
+		{name: "no time", ext: ".csv", content: "time,a\n,1\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSaved(tt.ext, []byte(tt.content))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got.Labels, tt.want.Labels) || !equalRows(got.Data, tt.want.Data) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayClock(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"system.cpu.csv": "time,a\n1000,1\n1001,2\n"})
+
+	// Starts warmup seconds after the first row
+	r, err := NewReplay(dir, 60, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Start != 1100 {
+		t.Errorf("starts at %v, want 1100", r.Start)
+	}
+
+	// Runs Speed times faster than real time
+	r.began = time.Now().Add(-10 * time.Second)
+	if now := r.Now(); now < 1700 || now > 1701 {
+		t.Errorf("10s in at speed 60 the clock reads %v, want 1700", now)
+	}
+
+	// Or starts where it's told
+	r, err = NewReplay(dir, 1, 5000, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Start != 5000 {
+		t.Errorf("starts at %v, want 5000", r.Start)
+	}
+
+	if _, err := NewReplay(filepath.Join(dir, "nope"), 1, 0, 0); err == nil {
+		t.Error("missing dir: got no error")
+	}
+	if _, err := NewReplay(t.TempDir(), 1, 0, 0); err == nil {
+		t.Error("dir with nothing saved: got no error")
+	}
+}
+
+func TestReplayFetch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		// Saved oldest first, with a missing value at 103
+		"system.cpu.csv":         "time,a\n100,1\n101,2\n102,3\n103,\n104,5\n105,6\n",
+		"host1/system.cpu.json":  `{"labels":["time","a"],"data":[[105,60],[104,50]]}`,
+		"host1/system.load.json": `{"labels":["time","load1"],"data":[[105,1]]}`,
+	})
+	r, err := NewReplay(dir, 1, 105, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stop the clock
+	r.Speed = 0
+
+	nan := math.NaN()
+	tests := []struct {
+		name string
+		host string
+		req  DataRequest
+		want [][]float64
+	}{
+		{"relative window, newest first", "other", DataRequest{Chart: "system.cpu", After: -3}, [][]float64{{105, 6}, {104, 5}, {103, nan}, {102, 3}}},
+		{"absolute window", "other", DataRequest{Chart: "system.cpu", After: 101, Before: 102}, [][]float64{{102, 3}, {101, 2}}},
+		{"points average groups", "other", DataRequest{Chart: "system.cpu", After: -5, Points: 3}, [][]float64{{105, 5.5}, {103, 3}, {101, 1.5}}},
+		{"points leave out missing", "other", DataRequest{Chart: "system.cpu", After: -5, Points: 2}, [][]float64{{105, 5.5}, {102, 2}}},
+		{"more points than rows", "other", DataRequest{Chart: "system.cpu", After: -1, Points: 10}, [][]float64{{105, 6}, {104, 5}}},
+		{"host dir first", "host1", DataRequest{Chart: "system.cpu", After: -5}, [][]float64{{105, 60}, {104, 50}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := r.Fetch(context.Background(), []Job{{Client: NewClient(tt.host), Request: tt.req}})[0]
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			if !equalRows(res.Data.Data, tt.want) {
+				t.Errorf("got %v, want %v", res.Data.Data, tt.want)
+			}
+
+			// The body reads back like the api's, missing values as null
+			// which decode to 0
+			body, err := decode(tt.req.Chart, res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, row := range tt.want {
+				for j, v := range row {
+					if math.IsNaN(v) {
+						v = 0
+					}
+					if body.Data[i][j] != v {
+						t.Errorf("body = %s, want %v", res.Body, tt.want)
+					}
+				}
+			}
+		})
+	}
+
+	res := r.Fetch(context.Background(), []Job{{Client: NewClient("other"), Request: DataRequest{Chart: "system.load"}}})[0]
+	if !errors.Is(res.Err, os.ErrNotExist) {
+		t.Errorf("chart only saved for another host gave %v, want not found", res.Err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res := r.Fetch(ctx, []Job{{Client: NewClient("host1"), Request: DataRequest{Chart: "system.cpu"}}})[0]; res.Err == nil {
+		t.Error("cancelled context: got no error")
+	}
+}
+
+func TestReplayCharts(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"system.cpu.csv":         "time,a\n100,1\n",
+		"host1/disk.sda.json":    `{"labels":["time","reads"],"data":[[100,1]]}`,
+		"host1/notes.txt":        "not a chart",
+		"host2/charts.json":      `{"hostname":"host2","charts":{"net.eth0":{"id":"net.eth0","family":"eth0","context":"net.net","type":"net","enabled":true}}}`,
+		"host2/system.load.json": `{"labels":["time","load1"],"data":[[100,1]]}`,
+	})
+	r, err := NewReplay(dir, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// From file names, shared and the host's own
+	charts, err := r.Charts("host1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charts) != 2 || charts["disk.sda"].Type != "disk" || charts["system.cpu"].Context != "system.cpu" {
+		t.Errorf("host1 charts = %+v, want disk.sda and system.cpu", charts)
+	}
+
+	// A saved charts.json wins
+	charts, err = r.Charts("host2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(charts) != 1 || charts["net.eth0"].Family != "eth0" {
+		t.Errorf("host2 charts = %+v, want net.eth0 from charts.json", charts)
+	}
+
+	selected, err := r.DiscoverCharts("host1", ChartFilter{Types: Globs{Include: []string{"system"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 1 || selected[0].ID != "system.cpu" {
+		t.Errorf("selected %+v, want system.cpu", selected)
+	}
+	if _, err := r.DiscoverCharts("host1", ChartFilter{IDs: Globs{Include: []string{"["}}}); err == nil {
+		t.Error("bad pattern: got no error")
+	}
+
+	// An archive lists what was recorded for the host
+	path := filepath.Join(t.TempDir(), "archive.jsonl.gz")
+	record(t, path, "system.cpu", 100)
+	r, err = NewReplay(path, 1, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if charts, err := r.Charts("example"); err != nil || len(charts) != 1 || charts["system.cpu"].ID != "system.cpu" {
+		t.Errorf("archive charts = %+v, %v, want system.cpu", charts, err)
+	}
+	if _, err := r.Charts("other"); err == nil {
+		t.Error("host not in the archive: got no error")
+	}
+}
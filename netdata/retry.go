@@ -0,0 +1,102 @@
+package netdata
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy says how a Client retries failed requests
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries, including the first
+	MaxAttempts int
+
+	// BaseDelay is the wait before the first retry, doubled each time after
+	BaseDelay time.Duration
+
+	// MaxDelay caps the wait between retries
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of each delay that is randomised, from 0 to 1
+	Jitter float64
+
+	// RetryStatus lists the http status codes worth trying again
+	RetryStatus []int
+}
+
+// DefaultRetryPolicy retries transient server errors a couple of times
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   200 * time.Millisecond,
+	MaxDelay:    5 * time.Second,
+	Jitter:      0.5,
+	RetryStatus: []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// Delay is how long to wait before the given retry, starting at 1
+func (p RetryPolicy) Delay(retry int) time.Duration {
+	d := float64(p.BaseDelay) * math.Pow(2, float64(retry-1))
+	if p.MaxDelay > 0 && d > float64(p.MaxDelay) {
+		d = float64(p.MaxDelay)
+	}
+
+	// Take a random amount off so clients that failed together spread out
+	if p.Jitter > 0 {
+		d -= d * p.Jitter * rand.Float64()
+	}
+	return time.Duration(d)
+}
+
+// Retryable reports whether a request that failed with err is worth trying again
+func (p RetryPolicy) Retryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		for _, code := range p.RetryStatus {
+			if statusErr.StatusCode == code {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Anything else is a connection level problem like a reset or a timeout
+	return true
+}
+
+// Run calls fn until it succeeds, fails with an error not worth retrying,
+// runs out of attempts or ctx is done
+func (p RetryPolicy) Run(ctx context.Context, fn func() error) error {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); err == nil || !p.Retryable(err) || attempt == attempts {
+			return err
+		}
+
+		// Wait before trying again, unless ctx runs out first
+		timer := time.NewTimer(p.Delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+	return err
+}
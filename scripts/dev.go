@@ -2,18 +2,22 @@ package main
 
 import (
 	"fmt"
+
+	"github.com/andrewm4894/learn-go/features"
 )
 
 func main() {
 	nSmooth := 2
 	fmt.Println(nSmooth)
-	x := [][]float64{1., 2., 3., 4., 5., 6., 7., 8., 9., 10.}
+	x := []float64{1., 2., 3., 4., 5., 6., 7., 8., 9., 10.}
 	fmt.Println(x)
-	//for i := range x {
-	//	if i > nSmooth {
-	//		fmt.Println(stat.Mean(x[i-nSmooth:i], nil))
-	//	}
-	//}
-	//mean := stat.Mean(x, nil)
-	//fmt.Printf("The mean of x is %.4f\n", mean)
+
+	// Try each smoothing method on x
+	for _, method := range []features.SmoothMethod{features.Mean, features.EWMA, features.Median} {
+		smoothed, err := features.Smooth(x, nSmooth, method)
+		if err != nil {
+			panic(err)
+		}
+		fmt.Printf("%v: %v\n", method, smoothed)
+	}
 }
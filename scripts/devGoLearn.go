@@ -1,35 +1,42 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"regexp"
+	"log"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/andrewm4894/learn-go/dataframes"
+	"github.com/andrewm4894/learn-go/netdata"
 	"github.com/go-gota/gota/dataframe"
 	"github.com/go-gota/gota/series"
-	"github.com/sjwhitworth/golearn/base"
+	"github.com/sjwhitworth/golearn/trees"
 )
 
-// Create a wait group
-var wg sync.WaitGroup
+// Result of fetching one chart, err is set if the fetch failed
+type dfResult struct {
+	chart string
+	df    dataframe.DataFrame
+	err   error
+}
 
-// Get api response (expects format=csv) and make a dataframe from it
-func getDf(url string, c chan dataframe.DataFrame) {
+// Get api response as csv and make a dataframe from it
+func getDf(ctx context.Context, wg *sync.WaitGroup, client *netdata.Client, req netdata.DataRequest, c chan dfResult) {
 
 	// Need to make sure we tell wait group we done
 	defer wg.Done()
 
-	// Pull chart name from the url
-	re := regexp.MustCompile("chart=(.*?)&")
-	match := re.FindStringSubmatch(url)
-	chart := match[1]
-	resp, _ := http.Get(url)
-
 	// Get body as string for ReadCSV
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
+	chart := req.Chart
+	req.Format = "csv"
+	bodyBytes, err := client.Get(ctx, req)
+	if err != nil {
+		c <- dfResult{chart: chart, err: err}
+		return
+	}
 	bodyString := string(bodyBytes)
 	df := dataframe.ReadCSV(strings.NewReader(bodyString))
 
@@ -43,38 +50,61 @@ func getDf(url string, c chan dataframe.DataFrame) {
 	}
 
 	// send df to channel
-	c <- df
+	c <- dfResult{chart: chart, df: df}
 
 }
 
 func main() {
 
-	// Define a list of api calls we want data from
+	host := flag.String("host", "london.my-netdata.io", "netdata host to get data from")
+	flag.Parse()
+
+	// Give up on any single request after 5 seconds and on the whole fetch after 10
+	client := netdata.NewClient(*host)
+	client.Timeout = 5 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Define a list of charts we want data from
 	// In this example we have an api call for each chart data we want in our df
-	urls := []string{
-		"https://london.my-netdata.io/api/v1/data?chart=system.cpu&format=csv&after=-100",
-		"https://london.my-netdata.io/api/v1/data?chart=system.io&format=csv&after=-100",
+	charts := []string{
+		"system.cpu",
+		"system.io",
 	}
 
 	// Create a channel of dataframes the size of number of api calls we need to make
-	dfChannel := make(chan dataframe.DataFrame, len(urls))
+	dfChannel := make(chan dfResult, len(charts))
 
 	// Create empty df we will outer join into from the df channel later
 	df := dataframe.ReadJSON(strings.NewReader(`[{"time":"1900-01-01 00:00:01"}]`))
 
-	// Kick off a go routine for each url
-	for _, url := range urls {
+	// Kick off a go routine for each chart
+	var wg sync.WaitGroup
+	for _, chart := range charts {
 		wg.Add(1)
-		go getDf(url, dfChannel)
+		go getDf(ctx, &wg, client, netdata.DataRequest{Chart: chart, After: -100}, dfChannel)
 	}
 
 	// Handle synchronization of channel
 	wg.Wait()
 	close(dfChannel)
 
-	// Pull each df from the channel and outer join onto our original empty df
-	for dfTmp := range dfChannel {
-		df = df.OuterJoin(dfTmp, "time")
+	// Pull each df from the channel and outer join onto our original empty df,
+	// carrying on with whatever charts did come back
+	var timedOut []string
+	for res := range dfChannel {
+		if res.err != nil {
+			if netdata.IsTimeout(res.err) {
+				timedOut = append(timedOut, res.chart)
+			} else {
+				log.Println(res.err)
+			}
+			continue
+		}
+		df = df.OuterJoin(res.df, "time")
+	}
+	if len(timedOut) > 0 {
+		fmt.Printf("Timed out fetching: %v\n", timedOut)
 	}
 
 	// Sort based on time
@@ -92,10 +122,20 @@ func main() {
 	// Print df
 	fmt.Println(df, 10, 5)
 
-	dataInstances, err := base.ParseCSVToInstances(df, true)
+	// Make golearn instances from every column but time, dropping rows
+	// where a chart had no data
+	dataInstances, err := dataframes.FromGota(df, dataframes.Options{NA: dataframes.DropNA})
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
+	rows, cols := dataInstances.Size()
+	fmt.Printf("Instances: %v rows, %v cols\n", rows, cols)
+
+	// Fit an isolation forest on all the charts together and score the same data
+	forest := trees.NewIsolationForest(10, 10, rows)
+	forest.Fit(dataInstances)
+	preds := forest.Predict(dataInstances)
+	fmt.Println("Anomaly scores:", preds)
 
 	// Describe df
 	//fmt.Println(df.Describe())
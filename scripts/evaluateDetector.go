@@ -0,0 +1,192 @@
+// Evaluate a detector on a labelled dataset, such as one made by
+// injectAnomalies. The detector and feature pipeline come from a config's
+// settings for a chart, it is fit on the first rows and scored on the rest.
+// Rows whose features take in a labelled anomaly are left out of the fit, so
+// the detector only learns what normal looks like.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/andrewm4894/learn-go/config"
+	"github.com/andrewm4894/learn-go/evaluate"
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/inject"
+	"github.com/andrewm4894/learn-go/netdata"
+	"gonum.org/v1/gonum/mat"
+)
+
+// The rows of x whose features come only from normal rows, lookback is how
+// many rows before a row go into its features. times are the rows the
+// features were made from.
+func normalRows(x *features.Matrix, times []int64, truth map[int64]bool, lookback int) *features.Matrix {
+	index := make(map[int64]int, len(times))
+	for i, t := range times {
+		index[t] = i
+	}
+	_, cols := x.Dims()
+	normal := &features.Matrix{Names: x.Names}
+	var data []float64
+	for k, t := range x.Times {
+		clean := true
+		for i := index[t]; i >= 0 && i >= index[t]-lookback && clean; i-- {
+			clean = !truth[times[i]]
+		}
+		if clean {
+			normal.Times = append(normal.Times, t)
+			data = append(data, x.RawRowView(k)...)
+		}
+	}
+	if len(normal.Times) > 0 {
+		normal.Dense = mat.NewDense(len(normal.Times), cols, data)
+	}
+	return normal
+}
+
+func main() {
+
+	data := flag.String("data", "./data/injected.csv", "labelled data, json or csv")
+	labelsPath := flag.String("labels", "./data/injected.labels.csv", "labels for the data")
+	configPath := flag.String("config", "", "config to take the detector and pipeline from, the defaults if empty")
+	chart := flag.String("chart", "system.cpu", "chart whose settings to use from the config's first host, with -config")
+	modelType := flag.String("model", "", "detector type, overriding the config's")
+	trainFrac := flag.Float64("train", 0.5, "fraction of rows to fit on, the rest are scored")
+	fillMissing := flag.String("fill", string(features.Zero), "how to fill missing values such as dropouts, drop, ffill, zero or linear")
+	thresholdList := flag.String("thresholds", "0.5,0.6,0.7,0.8,0.9", "comma separated score thresholds to report")
+	flag.Parse()
+
+	var thresholds []float64
+	for _, s := range strings.Split(*thresholdList, ",") {
+		t, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			log.Fatalf("bad threshold %q: %v", s, err)
+		}
+		thresholds = append(thresholds, t)
+	}
+
+	// Settings for the chart, the defaults if there is no config
+	settings := config.DefaultSettings
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		settings = cfg.Hosts[0].SettingsFor(*chart)
+	}
+	if *modelType != "" {
+		settings.Model.Type = *modelType
+	}
+
+	// Data and labels, keyed by time so they line up with the pipeline's rows
+	resp, err := netdata.ReadSaved(*data)
+	if err != nil {
+		log.Fatal(err)
+	}
+	frame, err := features.FromResponse(resp)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Dropouts are NaN, filling them with 0 matches how the client reads
+	// the nulls netdata sends for gaps
+	if frame, err = features.FillMissing(frame, features.Fill(*fillMissing)); err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.Open(*labelsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	labels, err := inject.ReadLabels(f)
+	f.Close()
+	if err != nil {
+		log.Fatal(err)
+	}
+	truth := make(map[int64]bool, len(labels))
+	for _, l := range labels {
+		truth[l.Time] = l.Anomaly
+	}
+
+	// Fit on the first rows
+	split := int(float64(len(frame.Rows)) * *trainFrac)
+	if split <= 0 || split >= len(frame.Rows) {
+		log.Fatalf("-train %v leaves no rows to fit or score", *trainFrac)
+	}
+	train := features.Frame{Names: frame.Names, Times: frame.Times[:split], Rows: frame.Rows[:split]}
+	pipeline := settings.Pipeline()
+	x, err := pipeline.Fit(train)
+	if err != nil {
+		log.Fatal(err)
+	}
+	lookback := pipeline.MinRows() - 1
+	if settings.Smoothing > 1 && !settings.Model.PerDimension() {
+		lookback += settings.Smoothing - 1
+	}
+	x = normalRows(x, train.Times, truth, lookback)
+	fitRows := len(x.Times)
+	if fitRows == 0 {
+		log.Fatal("every training row takes in a labelled anomaly, try a larger -train")
+	}
+	detector, err := settings.Model.Detector()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := detector.Fit(x); err != nil {
+		log.Fatal(err)
+	}
+
+	// Score everything so the first scored rows have their lags, then keep
+	// the rows after training
+	x, err = pipeline.Transform(frame)
+	if err != nil {
+		log.Fatal(err)
+	}
+	allScores, err := detector.Score(x)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var times []int64
+	var scores []float64
+	var anomalous []bool
+	for i, t := range x.Times {
+		if t <= train.Times[split-1] {
+			continue
+		}
+		l, ok := truth[t]
+		if !ok {
+			log.Fatalf("no label for time %v", t)
+		}
+		times = append(times, t)
+		scores = append(scores, allScores[i])
+		anomalous = append(anomalous, l)
+	}
+
+	// Report
+	fmt.Printf("%v on %v: fit on %v normal rows of the first %v, scored %v rows, %v events\n",
+		detector.Kind(), *data, fitRows, split, len(scores), len(evaluate.Ranges(anomalous)))
+	if auc, err := evaluate.ROCAUC(scores, anomalous); err != nil {
+		log.Println(err)
+	} else {
+		fmt.Printf("ROC-AUC: %.3f\n", auc)
+	}
+	if auc, err := evaluate.PRAUC(scores, anomalous); err != nil {
+		log.Println(err)
+	} else {
+		fmt.Printf("PR-AUC:  %.3f\n", auc)
+	}
+	fmt.Println("threshold precision recall f1    events detected range-recall delay(s) false-alarms")
+	for _, t := range thresholds {
+		c := evaluate.At(scores, anomalous, t)
+		ev, err := evaluate.Events(times, scores, anomalous, t)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%-9.2f %-9.3f %-6.3f %-5.3f %-6d %-8d %-12.3f %-8.1f %d\n",
+			t, c.Precision(), c.Recall(), c.F1(), ev.Events, ev.Detected, ev.RangeRecall, ev.MeanDelay, ev.FalseAlarms)
+	}
+
+}
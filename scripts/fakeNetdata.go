@@ -0,0 +1,39 @@
+// Run a fake netdata agent locally, point the other scripts at it with
+// -host http://localhost:19999 or use it as the host in a config
+
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/andrewm4894/learn-go/fakenetdata"
+)
+
+func main() {
+
+	addr := flag.String("addr", "localhost:19999", "address to listen on")
+	seed := flag.Int64("seed", 1, "seed for the made up data")
+	anomalyAfter := flag.Duration("anomaly-after", time.Minute, "when to start an anomaly on system.cpu, from now")
+	anomalyFor := flag.Duration("anomaly-for", 30*time.Second, "how long the anomaly lasts, none if 0")
+	flag.Parse()
+
+	server := fakenetdata.New(*seed)
+	if *anomalyFor > 0 {
+		start := time.Now().Add(*anomalyAfter).Unix()
+		server.Anomalies = append(server.Anomalies, fakenetdata.Anomaly{
+			Chart:      "system.cpu",
+			Start:      start,
+			End:        start + int64(anomalyFor.Seconds()),
+			Shift:      40,
+			NoiseScale: 3,
+		})
+		log.Printf("Anomaly on system.cpu from %v to %v\n", start, start+int64(anomalyFor.Seconds()))
+	}
+
+	log.Printf("Fake netdata listening on http://%v\n", *addr)
+	log.Fatal(http.ListenAndServe(*addr, server))
+
+}
@@ -1,59 +1,83 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/http"
+
+	"github.com/andrewm4894/learn-go/netdata"
 )
 
+// Every job sends exactly one of these back, err is set if it failed
 type urlResponses struct {
 	url    string
-	status bool
+	status int // http status, 0 if we never got a response
 	data   string
+	err    error
 }
 
 func main() {
-	urls := []string{
-		"https://london.my-netdata.io/api/v1/data?chart=system.cpu&after=-10",
-		"https://london.my-netdata.io/api/v1/data?chart=system.net&after=-10",
+	ctx := context.Background()
+	host := flag.String("host", "london.my-netdata.io", "netdata host to get data from")
+	flag.Parse()
+
+	// Failed requests are retried by the client, with backoff
+	client := netdata.NewClient(*host)
+	retry := netdata.DefaultRetryPolicy
+	client.Retry = &retry
+
+	charts := []string{
+		"system.cpu",
+		"system.net",
 	}
 
 	c := make(chan urlResponses)
-	for _, url := range urls {
-		go getData(url, c)
+	for _, chart := range charts {
+		go getData(ctx, client, netdata.DataRequest{Chart: chart, After: -10}, c)
 
 	}
-	result := make([]urlResponses, len(urls))
-	for i, _ := range result {
-		result[i] = <-c
-		if result[i].status {
-			fmt.Println(result[i].data)
+
+	// Collect one result per job
+	var failed []urlResponses
+	for range charts {
+		result := <-c
+		if result.err == nil {
+			fmt.Println(result.data)
 			//dataMap := map[string]string{}
-			//err := json.Unmarshal(result[i].data, &dataMap)
+			//err := json.Unmarshal(result.data, &dataMap)
 			//if err != nil {
 			//	log.Fatal(err.Error())
 			//}
 			//fmt.Println(dataMap)
+			continue
 		}
+		failed = append(failed, result)
+	}
+
+	// Report anything we could not get
+	for _, result := range failed {
+		fmt.Printf("Failed %v (status %v): %v\n", result.url, result.status, result.err)
 	}
 
 }
 
-func getData(url string, c chan urlResponses) {
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer resp.Body.Close()
+func getData(ctx context.Context, client *netdata.Client, req netdata.DataRequest, c chan urlResponses) {
+	result := urlResponses{url: client.DataURL(req)}
 
-	if resp.StatusCode == http.StatusOK {
-		bodyBytes, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatal(err)
+	bodyBytes, err := client.Get(ctx, req)
+	if err != nil {
+		result.err = err
+		var statusErr *netdata.StatusError
+		if errors.As(err, &statusErr) {
+			result.status = statusErr.StatusCode
 		}
-		bodyString := string(bodyBytes)
-		c <- urlResponses{url, true, bodyString}
-		//c <- urlResponses{url, true, bodyBytes}
+		c <- result
+		return
 	}
+
+	result.status = http.StatusOK
+	result.data = string(bodyBytes)
+	c <- result
 }
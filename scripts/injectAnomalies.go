@@ -0,0 +1,121 @@
+// Make a labelled dataset by injecting anomalies into a saved chart, or
+// into made up data if no input is given. The data can be replayed by
+// putting it in a replay dir named after its chart.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/andrewm4894/learn-go/fakenetdata"
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/inject"
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+// Made up data for chart from the fake netdata server, one row a second
+// ending now
+func synthetic(chart string, rows int, seed int64) (features.Frame, error) {
+	server := fakenetdata.New(seed)
+	for _, c := range server.Charts {
+		if c.ID != chart {
+			continue
+		}
+		f := features.Frame{}
+		for _, d := range c.Dimensions {
+			f.Names = append(f.Names, d.Name)
+		}
+		end := time.Now().Unix()
+		for t := end - int64(rows) + 1; t <= end; t++ {
+			row := make([]float64, len(c.Dimensions))
+			for j, d := range c.Dimensions {
+				row[j] = server.Value(c, d, t)
+			}
+			f.Times = append(f.Times, t)
+			f.Rows = append(f.Rows, row)
+		}
+		return f, nil
+	}
+	return features.Frame{}, fmt.Errorf("fake netdata has no chart %v", chart)
+}
+
+func main() {
+
+	in := flag.String("in", "", "saved json or csv response to inject into, made up data if empty")
+	chart := flag.String("chart", "system.cpu", "chart to make up data for")
+	rows := flag.Int("rows", 3600, "rows of made up data")
+	n := flag.Int("n", 10, "anomalies to inject")
+	kindList := flag.String("kinds", "", "comma separated kinds of anomaly, all of them if empty")
+	maxLength := flag.Int("max-length", 60, "longest anomaly in rows")
+	gap := flag.Int("gap", 60, "fewest rows between anomalies")
+	seed := flag.Int64("seed", 1, "random seed")
+	out := flag.String("out", "./data/injected.csv", "where to write the data")
+	labelsOut := flag.String("labels", "./data/injected.labels.csv", "where to write the labels")
+	flag.Parse()
+
+	var kinds []inject.Kind
+	if *kindList != "" {
+		for _, k := range strings.Split(*kindList, ",") {
+			if kind := inject.Kind(k); kind.Valid() {
+				kinds = append(kinds, kind)
+			} else {
+				log.Fatalf("unknown kind %q, want some of %v", k, inject.Kinds)
+			}
+		}
+	}
+
+	// Get the clean data
+	var frame features.Frame
+	if *in != "" {
+		resp, err := netdata.ReadSaved(*in)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if frame, err = features.FromResponse(resp); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		var err error
+		if frame, err = synthetic(*chart, *rows, *seed); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Inject the anomalies
+	rng := rand.New(rand.NewSource(*seed))
+	injections, err := inject.Random(rng, len(frame.Rows), len(frame.Names), *n, kinds, *maxLength, *gap)
+	if err != nil {
+		log.Fatal(err)
+	}
+	frame, events, err := inject.Apply(frame, injections)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, e := range events {
+		fmt.Printf("%v %v from %v to %v\n", e.Kind, e.Columns, e.Start, e.End)
+	}
+
+	// Write the data and labels
+	write := func(path string, fn func(f *os.File) error) {
+		f, err := os.Create(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := fn(f); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	write(*out, func(f *os.File) error { return inject.WriteCSV(f, frame) })
+	write(*labelsOut, func(f *os.File) error { return inject.WriteLabels(f, inject.Labels(frame.Times, events)) })
+	fmt.Printf("Wrote %v rows to %v and labels to %v\n", len(frame.Rows), *out, *labelsOut)
+
+}
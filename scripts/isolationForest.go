@@ -3,16 +3,51 @@
 package main
 
 import (
+	"encoding/csv"
 	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
 
+	"github.com/andrewm4894/learn-go/anomaly"
 	"github.com/sjwhitworth/golearn/base"
 	"github.com/sjwhitworth/golearn/trees"
+	"gonum.org/v1/gonum/mat"
 )
 
+// Read a csv of numbers with a header row into a matrix
+func readDense(path string) (*mat.Dense, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%v: no rows", path)
+	}
+	records = records[1:]
+	var data []float64
+	for _, record := range records {
+		for _, field := range record {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, v)
+		}
+	}
+	return mat.NewDense(len(records), len(records[0]), data), nil
+}
+
 func main() {
 	/* Isolation Forest is used for outlier detection
 		 The algorithm works by randomly splitting the data, so results won't be exactly reproducible
-	 	 but generally outliers will still be classified as outliers. */
+	 	 but generally outliers will still be classified as outliers.
+		 See the end for anomaly.IForest, which takes a seed and is reproducible. */
 
 	// Load data for outlier detection - includes gaussian distribution, and ten outliers at the end
 	// Dataset has 1000 normal datapoints, and 10 outliers at the ned
@@ -54,4 +89,25 @@ func main() {
 		fmt.Print("      ")
 		fmt.Println(preds[i])
 	}
+
+	// Same again with our own forest, which takes its randomness from a
+	// seeded rand.Rand so the same seed always gives the same scores, see
+	// anomaly/iforest_test.go
+	x, err := readDense("./data/gaussian_outliers.csv")
+	if err != nil {
+		panic(err)
+	}
+	iforest := anomaly.NewIForest(100, 850, 100, 0, rand.New(rand.NewSource(42)))
+	if err := iforest.FitDense(x); err != nil {
+		panic(err)
+	}
+	scores, err := iforest.ScoreDense(x)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Native anomaly scores for outliers are ")
+	for i := 1000; i < 1010; i++ {
+		fmt.Print("      ")
+		fmt.Println(scores[i])
+	}
 }
@@ -1,52 +1,41 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
+
+	"github.com/andrewm4894/learn-go/netdata"
 )
 
 func main() {
 
-	urls := []string{
-		"https://london.my-netdata.io/api/v1/data?chart=system.cpu&after=-2",
-		"https://london.my-netdata.io/api/v1/data?chart=system.net&after=-2",
-		"https://london.my-netdata.io/api/v1/data?chart=system.load&after=-2",
-		"https://london.my-netdata.io/api/v1/data?chart=system.io&after=-2",
-	}
-
-	jobs := make(chan string, len(urls))
-	results := make(chan string, len(urls))
+	ctx := context.Background()
+	host := flag.String("host", "london.my-netdata.io", "netdata host to get data from")
+	flag.Parse()
+	client := netdata.NewClient(*host)
 
-	go worker(jobs, results)
-	go worker(jobs, results)
-
-	for _, url := range urls {
-		jobs <- url
+	charts := []string{
+		"system.cpu",
+		"system.net",
+		"system.load",
+		"system.io",
 	}
-	close(jobs)
 
-	for j := 0; j < len(urls); j++ {
-		fmt.Println(<-results)
-	}
-}
+	// Two workers, and no more than two requests at a time against the host
+	pool := netdata.NewPool(netdata.PoolOptions{Workers: 2, PerHost: 2, QueueSize: len(charts)})
+	defer pool.Close()
 
-func worker(jobs <-chan string, results chan<- string) {
-	for url := range jobs {
-		results <- getData(url)
+	jobs := make([]netdata.Job, len(charts))
+	for i, chart := range charts {
+		jobs[i] = netdata.Job{Client: client, Request: netdata.DataRequest{Chart: chart, After: -2}}
 	}
-}
 
-func getData(url string) string {
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Fatal(err)
+	for _, result := range pool.Fetch(ctx, jobs) {
+		if result.Err != nil {
+			fmt.Println(result.Err)
+			continue
+		}
+		fmt.Println(string(result.Body))
 	}
-	defer resp.Body.Close()
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	bodyString := string(bodyBytes)
-
-	return bodyString
 }
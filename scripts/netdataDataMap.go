@@ -1,102 +1,104 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"log"
 	"sync"
+	"time"
 
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/netdata"
 	"gonum.org/v1/gonum/mat"
 )
 
-// Create a wait group
-var wg sync.WaitGroup
-
-type netdataResponse struct {
-	Labels []string    `json:"labels"`
-	Data   [][]float64 `json:"data"`
+// Result of fetching one chart, err is set if the fetch failed
+type dataResult struct {
+	chart string
+	data  *netdata.Response
+	err   error
 }
 
 // Get data from api
-func getData(url string, c chan netdataResponse) {
-
-	// Create data to store response
-	var data netdataResponse
+func getData(ctx context.Context, wg *sync.WaitGroup, client *netdata.Client, req netdata.DataRequest, c chan dataResult) {
 
 	// Need to make sure we tell wait group we done
 	defer wg.Done()
 
 	// Get response
-	resp, _ := http.Get(url)
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
-
-	// Unmarshal into dataMap
-	_ = json.Unmarshal([]byte(bodyBytes), &data)
+	data, err := client.Data(ctx, req)
 
 	// Send to channel
-	c <- data
+	c <- dataResult{chart: req.Chart, data: data, err: err}
 
 }
 
 func main() {
 
+	host := flag.String("host", "london.my-netdata.io", "netdata host to get data from")
+	flag.Parse()
+
+	// Give up on any single request after 5 seconds and on the whole fetch after 10
+	client := netdata.NewClient(*host)
+	client.Timeout = 5 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	// Define a list of api calls we want data from
-	urls := []string{
-		//"https://london.my-netdata.io/api/v1/data?chart=system.cpu&format=json&after=-4",
-		"https://london.my-netdata.io/api/v1/data?chart=system.net&format=json&after=-20",
-		//"https://london.my-netdata.io/api/v1/data?chart=system.load&format=json&after=-4",
-		//"https://london.my-netdata.io/api/v1/data?chart=system.io&format=json&after=-3",
+	reqs := []netdata.DataRequest{
+		//{Chart: "system.cpu", After: -4},
+		{Chart: "system.net", After: -20},
+		//{Chart: "system.load", After: -4},
+		//{Chart: "system.io", After: -3},
 	}
 
 	// Create a channel the size of number of api calls we need to make
-	dataChannel := make(chan netdataResponse, len(urls))
+	dataChannel := make(chan dataResult, len(reqs))
 
-	// Kick off a go routine for each url
-	for _, url := range urls {
+	// Kick off a go routine for each request
+	var wg sync.WaitGroup
+	for _, req := range reqs {
 		wg.Add(1)
-		go getData(url, dataChannel)
+		go getData(ctx, &wg, client, req, dataChannel)
 	}
 
 	// Handle synchronization of channel
 	wg.Wait()
 	close(dataChannel)
 
-	// Pull each response from channel
-	for data := range dataChannel {
-
-		// Flatten data into one slice, ignoring the first column which is "time", and adding lags_n
-		nLags := 3
-		nData := len(data.Data)
-		nDims := len(data.Labels) - 1
-		nCols := nDims + (nLags * nDims)
-		nRows := nData - nLags
-		fmt.Println(nCols)
-		fmt.Println(nRows)
-		dataFlat := make([]float64, nCols*nRows)
-		i := 0
-		for t := range data.Data {
-			fmt.Println(data.Data[t])
-			if t >= nLags {
-				for dim := range data.Data[t] {
-					if dim > 0 {
-						for l := 0; l <= nLags; l++ {
-							dataFlat[i] = data.Data[t-l][dim]
-							i++
-						}
-					}
-				}
+	// Pull each response from channel, carrying on with whatever charts did
+	// come back
+	var timedOut []string
+	for res := range dataChannel {
+		if res.err != nil {
+			if netdata.IsTimeout(res.err) {
+				timedOut = append(timedOut, res.chart)
+			} else {
+				log.Println(res.err)
 			}
+			continue
 		}
 
-		fmt.Printf("%v\n", dataFlat)
-
-		// Create gonum dense matrix from dataFlat
-		X := mat.NewDense(nRows, nCols, dataFlat)
+		// Make a matrix with lags_n, ignoring the first column which is "time"
+		nLags := 3
+		frame, err := features.FromResponse(res.data)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		X, err := features.NewPipeline(features.Lag{N: nLags}).Transform(frame)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
 
 		// Print matrix X
 		fmt.Printf("X:\n %v\n\n", mat.Formatted(X, mat.Prefix(" "), mat.Excerpt(10)))
 
 	}
+	if len(timedOut) > 0 {
+		fmt.Printf("Timed out fetching: %v\n", timedOut)
+	}
 
 }
@@ -1,123 +1,76 @@
 package main
 
 import (
-	"fmt"
-	"io/ioutil"
-	"net/http"
-	"regexp"
 	"context"
-	"strings"
-	"sync"
+	"flag"
+	"fmt"
+	"log"
+	"time"
 
-	dataframe "github.com/rocketlaunchr/dataframe-go"
+	"github.com/andrewm4894/learn-go/dataframes"
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/netdata"
 )
 
-// Create a wait group
-var wg sync.WaitGroup
-
-var ctx = context.Background()
-
-// Get api response (expects format=csv) and make a dataframe from it
-func getDf(url string, c chan dataframe) {
-
-	// Need to make sure we tell wait group we done
-	defer wg.Done()
-
-	// Pull chart name from the url
-	re := regexp.MustCompile("chart=(.*?)&")
-	match := re.FindStringSubmatch(url)
-	chart := match[1]
-	resp, _ := http.Get(url)
-
-	// Get body as string for ReadCSV
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
-	bodyString := string(bodyBytes)
-	//csvStr := csv.NewReader(bodyString)
-
-	opts := dataframe.CSVLoadOptions{
-		InferDataTypes: true,
-		NilValue:       &[]string{"NA"}[0],
-	}
+func main() {
 
-	df, err := LoadFromCSV(ctx, strings.NewReader(bodyString), opts)
-	if err != nil {
-		fmt.Println("csv import error: %v", err)
-		return
+	// Run each backend a few times so the timings mean something
+	runs := flag.Int("runs", 5, "times to run each backend")
+	host := flag.String("host", "london.my-netdata.io", "netdata host to get data from")
+	flag.Parse()
+	if *runs < 1 {
+		log.Fatalf("-runs must be at least 1, got %v", *runs)
 	}
 
-	fmt.Println(df)
-
-	//df := dataframe.ReadCSV(strings.NewReader(bodyString))
-
-	// Add chart suffix to each col name
-	// (ignore first col which should be "time" and used for joins later)
-	//colnames := df.Names()
-	//for i, colname := range colnames {
-	//	if i != 0 {
-	//		df = df.Rename(chart+"|"+colname, colname)
-	//	}
-	//}
-
-	// send df to channel
-	c <- df
+	client := netdata.NewClient(*host)
+	pool := netdata.NewPool(netdata.PoolOptions{Workers: 4, PerHost: 4})
+	defer pool.Close()
+	ctx := context.Background()
 
-}
-
-func main() {
-
-	// Define a list of api calls we want data from
+	// Define a list of charts we want data from
 	// In this example we have an api call for each chart data we want in our df
-	urls := []string{
-		"https://london.my-netdata.io/api/v1/data?chart=system.cpu&format=csv&after=-10",
-		"https://london.my-netdata.io/api/v1/data?chart=system.net&format=csv&after=-10",
-		"https://london.my-netdata.io/api/v1/data?chart=system.load&format=csv&after=-10",
-		"https://london.my-netdata.io/api/v1/data?chart=system.io&format=csv&after=-10",
+	charts := []string{
+		"system.cpu",
+		"system.net",
+		"system.load",
+		"system.io",
 	}
-
-	// Create a channel of dataframes the size of number of api calls we need to make
-	dfChannel := make(chan dataframe.DataFrame, len(urls))
-
-	// Create empty df we will outer join into from the df channel later
-
-	csvStr := `
-time
-1900-01-01 00:01:00
-`
-	opts := dataframe.CSVLoadOptions{
-		InferDataTypes: true,
-		NilValue:       &[]string{"NA"}[0],
+	reqs := make([]netdata.DataRequest, len(charts))
+	for i, chart := range charts {
+		reqs[i] = netdata.DataRequest{Chart: chart, After: -10}
 	}
 
-	df, err := dataframe.LoadFromCSV(ctx, strings.NewReader(csvStr), opts)
+	// Fetch the charts once, then merge the same bytes with each backend so
+	// only the merge is timed
+	bodies, err := dataframes.FetchCSV(ctx, pool, client, reqs)
 	if err != nil {
-		t.Errorf("csv import error: %v", err)
-		return
+		log.Fatal(err)
 	}
-	
-	//df := dataframe.ReadJSON(strings.NewReader(`[{"time":"1900-01-01 00:00:01"}]`))
-
-	// Kick off a go routine for each url
-	for _, url := range urls {
-		wg.Add(1)
-		go getDf(url, dfChannel)
+	frames := make(map[string]features.Frame)
+	for _, name := range dataframes.BackendNames() {
+		backend, _ := dataframes.BackendFor(name)
+		var total time.Duration
+		for i := 0; i < *runs; i++ {
+			start := time.Now()
+			frame, err := backend.Merge(ctx, charts, bodies)
+			if err != nil {
+				log.Fatalf("%v: %v", name, err)
+			}
+			total += time.Since(start)
+			frames[name] = frame
+		}
+		frame := frames[name]
+		fmt.Printf("%v: %v rows, %v cols, %v per run\n", name, len(frame.Rows), len(frame.Names), total/time.Duration(*runs))
 	}
 
-	// Handle synchronization of channel
-	wg.Wait()
-	close(dfChannel)
-
-	// Pull each df from the channel and outer join onto our original empty df
-	for dfTmp := range dfChannel {
-		df = df.OuterJoin(dfTmp, "time")
+	// Print the merged frame from dataframe-go with the gaps filled in
+	frame, err := features.FillMissing(frames["dataframe-go"], features.FFill)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(frame.Names)
+	for r, row := range frame.Rows {
+		fmt.Println(frame.Times[r], row)
 	}
-
-	// Sort based on time
-	//df = df.Arrange(dataframe.Sort("time"))
-
-	// Print df
-	fmt.Println(df, 10, 5)
-
-	// Describe df
-	//fmt.Println(df.Describe())
 
 }
@@ -1,81 +1,66 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
+	"log"
 	"sync"
+	"time"
 
+	"github.com/andrewm4894/learn-go/anomaly"
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/netdata"
 	"github.com/sjwhitworth/golearn/base"
 	"github.com/sjwhitworth/golearn/trees"
 	"gonum.org/v1/gonum/mat"
 )
 
-// Create a wait group
-var wg sync.WaitGroup
-
 // LagsN defines number of lags to make
 var LagsN = 2
 
-// Urls define a list of api calls we want data from
-var Urls = [1]string{
-	//"https://london.my-netdata.io/api/v1/data?chart=system.cpu&format=json&after=-4",
-	"https://london.my-netdata.io/api/v1/data?chart=system.net&format=json&after=-10",
-	//"https://london.my-netdata.io/api/v1/data?chart=system.load&format=json&after=-4",
-	//"https://london.my-netdata.io/api/v1/data?chart=system.io&format=json&after=-3",
+// Reqs define a list of api calls we want data from
+var Reqs = []netdata.DataRequest{
+	//{Chart: "system.cpu", After: -4},
+	{Chart: "system.net", After: -10},
+	//{Chart: "system.load", After: -4},
+	//{Chart: "system.io", After: -3},
 }
 
-type netdataResponse struct {
-	Labels []string    `json:"labels"`
-	Data   [][]float64 `json:"data"`
+// A matrix for one chart with the timestamp of each of its rows, err is set
+// if getting it failed
+type timedX struct {
+	chart string
+	times []int64
+	x     mat.Dense
+	err   error
 }
 
 // Get a gonum matrix from the netdata api with specified nLags
-func getX(url string, nLags int, c chan mat.Dense) {
-
-	// Create data to store response
-	var data netdataResponse
+func getX(ctx context.Context, wg *sync.WaitGroup, client *netdata.Client, req netdata.DataRequest, nLags int, c chan timedX) {
 
 	// Need to make sure we tell wait group we done
 	defer wg.Done()
 
 	// Get response
-	resp, _ := http.Get(url)
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
-
-	// Unmarshal into dataMap
-	_ = json.Unmarshal([]byte(bodyBytes), &data)
-
-	// Flatten data into one slice, ignoring the first column which is "time", and adding nLags
-	nData := len(data.Data)
-	nDims := len(data.Labels) - 1
-	nCols := nDims + (nLags * nDims)
-	nRows := nData - nLags
-
-	// Make flat slice to put data into
-	dataFlat := make([]float64, nCols*nRows)
-
-	// Loop over and add lags to flat data
-	i := 0
-	for t := range data.Data {
-		fmt.Println(data.Data[t])
-		if t >= nLags {
-			for dim := range data.Data[t] {
-				// Ignore time which is the first dim in the response
-				if dim > 0 {
-					// Add each lag
-					for l := 0; l <= nLags; l++ {
-						dataFlat[i] = data.Data[t-l][dim]
-						i++
-					}
-				}
-			}
-		}
+	data, err := client.Data(ctx, req)
+	if err != nil {
+		c <- timedX{chart: req.Chart, err: err}
+		return
 	}
 
-	// Create gonum dense matrix from dataFlat
-	x := mat.NewDense(nRows, nCols, dataFlat)
+	// Create gonum dense matrix with nLags, keeping the "time" column aside in x.Times
+	frame, err := features.FromResponse(data)
+	if err != nil {
+		c <- timedX{chart: req.Chart, err: err}
+		return
+	}
+	x, err := features.NewPipeline(features.Lag{N: nLags}).Transform(frame)
+	if err != nil {
+		c <- timedX{chart: req.Chart, err: err}
+		return
+	}
+	nRows, _ := x.Dims()
 
 	// Create vector of zeros
 	zeros := make([]float64, nRows)
@@ -87,31 +72,51 @@ func getX(url string, nLags int, c chan mat.Dense) {
 	fmt.Printf("xFinal:\n %v", xFinal)
 
 	// Send to channel
-	c <- xFinal
+	c <- timedX{chart: req.Chart, times: x.Times, x: xFinal}
 
 }
 
 func main() {
 
+	host := flag.String("host", "london.my-netdata.io", "netdata host to get data from")
+	flag.Parse()
+
+	// Give up on any single request after 5 seconds and on the whole fetch after 10
+	client := netdata.NewClient(*host)
+	client.Timeout = 5 * time.Second
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	// Create a channel the size of number of api calls we need to make
-	dataChannel := make(chan mat.Dense, len(Urls))
+	dataChannel := make(chan timedX, len(Reqs))
 
-	// Kick off a go routine for each url
-	for _, url := range Urls {
+	// Kick off a go routine for each request
+	var wg sync.WaitGroup
+	for _, req := range Reqs {
 		wg.Add(1)
-		go getX(url, LagsN, dataChannel)
+		go getX(ctx, &wg, client, req, LagsN, dataChannel)
 	}
 
 	// Handle synchronization of channel
 	wg.Wait()
 	close(dataChannel)
 
-	// Pull each response from channel
-	for x := range dataChannel {
+	// Pull each response from channel, carrying on with whatever charts did
+	// come back
+	var timedOut []string
+	for data := range dataChannel {
+		if data.err != nil {
+			if netdata.IsTimeout(data.err) {
+				timedOut = append(timedOut, data.chart)
+			} else {
+				log.Println(data.err)
+			}
+			continue
+		}
 
 		// Create instances
-		r, c := x.Dims()
-		instances := base.NewDenseCopy(base.InstancesFromMat64(r, c, &x))
+		r, c := data.x.Dims()
+		instances := base.NewDenseCopy(base.InstancesFromMat64(r, c, &data.x))
 
 		// Set a class attribute
 		attrArray := instances.AllAttributes()
@@ -140,14 +145,23 @@ func main() {
 				min = temp
 			}
 		}
-		fmt.Println(avgScore / 1000)
+		fmt.Println(avgScore / float64(len(preds)))
 		fmt.Println(min)
 
+		// Line the scores up with the time of each row
+		scores, err := anomaly.NewSeries(data.chart, data.times, preds)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
 		fmt.Println("Anomaly Scores are ")
-		for i := range preds {
-			fmt.Println(preds[i])
+		for _, p := range scores.Points {
+			fmt.Printf("%v %v\n", p.Time, p.Score)
 		}
 
 	}
+	if len(timedOut) > 0 {
+		fmt.Printf("Timed out fetching: %v\n", timedOut)
+	}
 
 }
@@ -1,194 +1,368 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io/ioutil"
-	"net/http"
-	"sync"
+	"log"
+	"os"
+	"sort"
 	"time"
 
-	"github.com/sjwhitworth/golearn/base"
-	"github.com/sjwhitworth/golearn/trees"
-	"gonum.org/v1/gonum/mat"
+	"github.com/andrewm4894/learn-go/anomaly"
+	"github.com/andrewm4894/learn-go/config"
+	"github.com/andrewm4894/learn-go/features"
+	"github.com/andrewm4894/learn-go/netdata"
 )
 
-// Create a wait group
-var wg sync.WaitGroup
+// A chart to model and its settings from the config, or a group of charts
+// joined on time with chart being the group's name
+type chartConf struct {
+	host     config.Host
+	chart    string
+	group    *config.Group
+	settings config.Settings
+}
+
+// Charts to fetch for conf
+func (conf chartConf) charts() []string {
+	if conf.group != nil {
+		return conf.group.Charts
+	}
+	return []string{conf.chart}
+}
 
-// Struct used to unmarshal json from netdata api
-type netdataResponse struct {
-	Labels []string    `json:"labels"`
-	Data   [][]float64 `json:"data"`
+// Key for conf's model and scores, "host|chart"
+func (conf chartConf) key(cfg *config.Config) string {
+	return cfg.Client(conf.host).Host() + "|" + conf.chart
 }
 
-// Get instances from the netdata api
-func getInstances(host, chart, after, before string, lags, diffs, smoothing int, c chan map[string]base.FixedDataGrid) {
-
-	// Need to make sure we tell wait group we done
-	defer wg.Done()
-
-	// Create response
-	var data netdataResponse
-
-	// Get response from netdata rest api
-	resp, _ := http.Get("https://" + host + "/api/v1/data?chart=" + chart + "&format=json&after=" + after + "&before=" + before)
-	bodyBytes, _ := ioutil.ReadAll(resp.Body)
-
-	// Unmarshal into netdataResponse
-	_ = json.Unmarshal([]byte(bodyBytes), &data)
-
-	// Flatten data into one slice, ignoring the first column which is always "time", and adding nLags
-	nDims := len(data.Labels) - 1
-	nCols := nDims + (lags * nDims)
-	nRows := len(data.Data) - lags
-
-	// Make flat slice to put data into
-	dataFlat := make([]float64, nCols*nRows)
-
-	// Loop over and add lags to flat data
-	i := 0
-	for t := range data.Data {
-		//fmt.Println(data.Data[t])
-		if t >= (lags + diffs) {
-			for dim := range data.Data[t] {
-				// Ignore time which is the first dim in the response
-				if dim > 0 {
-					// Add each lag
-					for l := 0; l <= lags; l++ {
-						if diffs > 0 {
-							dataFlat[i] = data.Data[t-l][dim] - data.Data[t-l-diffs][dim]
-						} else {
-							dataFlat[i] = data.Data[t-l][dim]
-						}
-						i++
+// Result of getting instances for one chart, key is "host|chart"
+type instancesResult struct {
+	key  string
+	conf chartConf
+	x    *features.Matrix
+	err  error
+}
+
+// Get the charts to model for each host in cfg, either those listed in the
+// config or those discovered on the host, or in the replay if there is one,
+// that pass its filter. A host whose discovery fails keeps its charts from
+// previous.
+func discoverCharts(ctx context.Context, cfg *config.Config, replay *netdata.Replay, previous []chartConf) []chartConf {
+	var confs []chartConf
+	for _, host := range cfg.Hosts {
+		chartIDs := host.Charts
+		if len(chartIDs) == 0 {
+			var charts []netdata.Chart
+			var err error
+			if replay != nil {
+				charts, err = replay.DiscoverCharts(cfg.Client(host).Host(), host.Filter)
+			} else {
+				charts, err = cfg.Client(host).DiscoverCharts(ctx, host.Filter)
+			}
+			if err != nil {
+				log.Printf("Could not discover charts on %v: %v\n", host.Host, err)
+				for _, conf := range previous {
+					if conf.host.Host == host.Host {
+						confs = append(confs, conf)
 					}
 				}
+				continue
+			}
+			for _, chart := range charts {
+				chartIDs = append(chartIDs, chart.ID)
 			}
 		}
+		for _, chart := range chartIDs {
+			confs = append(confs, chartConf{host: host, chart: chart, settings: host.SettingsFor(chart)})
+		}
+		for i := range host.Groups {
+			group := &host.Groups[i]
+			confs = append(confs, chartConf{host: host, chart: group.Name, group: group, settings: host.SettingsForGroup(*group)})
+		}
 	}
+	return confs
+}
 
-	// Create instances
-	instances := base.InstancesFromMat64(nRows, nCols, mat.NewDense(nRows, nCols, dataFlat))
-	//fmt.Println(instances)
+// Get instances from the netdata api for each chart in confs, window gives
+// the after, before and points to fetch for each one
+func getInstances(ctx context.Context, cfg *config.Config, source netdata.Source, confs []chartConf, window func(conf chartConf) netdata.DataRequest) []instancesResult {
+
+	// Make a job for each chart, groups get one for each of their charts
+	var jobs []netdata.Job
+	for _, conf := range confs {
+		for _, chart := range conf.charts() {
+			req := window(conf)
+			req.Chart = chart
+			jobs = append(jobs, netdata.Job{Client: cfg.Client(conf.host), Request: req})
+		}
+	}
+
+	// Get responses from netdata rest api, these come back in the same order as jobs
+	fetched := source.Fetch(ctx, jobs)
+	results := make([]instancesResult, len(confs))
+	for i, conf := range confs {
+		results[i].key = conf.key(cfg)
+		results[i].conf = conf
+		charts := conf.charts()
+		frames := make([]features.Frame, len(charts))
+		for j := range charts {
+			res := fetched[0]
+			fetched = fetched[1:]
+			if res.Err != nil && results[i].err == nil {
+				results[i].err = res.Err
+			}
+			if res.Err == nil {
+				var err error
+				frames[j], err = features.FromResponse(res.Data)
+				if err != nil && results[i].err == nil {
+					results[i].err = fmt.Errorf("%v: %w", charts[j], err)
+				}
+			}
+		}
+		if results[i].err != nil {
+			continue
+		}
 
-	// Must set a class attribute in golearn
-	// Ok to just use any feature as per comment here:
-	// https://github.com/sjwhitworth/golearn/issues/260#issuecomment-756086922
-	attrArray := instances.AllAttributes()
-	instances.AddClassAttribute(attrArray[0])
+		// Groups are joined on time into one frame first
+		frame := frames[0]
+		if conf.group != nil {
+			var err error
+			if frame, err = features.Join(charts, frames, conf.group.Join); err != nil {
+				results[i].err = err
+				continue
+			}
+		}
 
-	// Create map for data so we can later identify what comes back from the channel
-	instancesMap := make(map[string]base.FixedDataGrid, 1)
-	instancesMap[host+"|"+chart] = instances
+		// Smooth, diff and lag each dimension, the time column is kept aside in x.Times
+		results[i].x, results[i].err = conf.settings.Pipeline().Transform(frame)
+	}
 
-	// Send to channel
-	c <- instancesMap
+	return results
+}
 
+// Feature config for a chart's settings, columns and training window are
+// filled in from x if it is given. Per dimension models get the raw
+// dimensions so have no feature settings.
+func featureConfig(settings config.Settings, x *features.Matrix) anomaly.FeatureConfig {
+	fc := anomaly.FeatureConfig{
+		TrainPoints: settings.TrainPoints,
+		Step:        settings.TrainStep(),
+		Params:      settings.Model.Params(),
+	}
+	if !settings.Model.PerDimension() {
+		fc.Lags = settings.Lags
+		fc.Diffs = settings.Diffs
+		fc.Smoothing = settings.Smoothing
+		fc.SmoothingMethod = settings.SmoothingMethod
+	}
+	if x != nil {
+		fc.Columns = x.Names
+		fc.TrainStart = x.Times[0]
+		fc.TrainEnd = x.Times[len(x.Times)-1]
+	}
+	return fc
 }
 
-func fitModel(instances base.FixedDataGrid, nTrees, maxDepth, subSpace int) trees.IsolationForest {
-	forest := trees.NewIsolationForest(nTrees, maxDepth, subSpace)
-	forest.Fit(instances)
-	return forest
+// Load the saved model for conf from dir, refusing it if its features do
+// not match the config
+func loadModel(dir string, conf chartConf, key string) (*anomaly.Model, error) {
+	model, err := anomaly.LoadModel(anomaly.ModelPath(dir, key))
+	if err != nil {
+		return nil, err
+	}
+	if err := model.Features.Check(featureConfig(conf.settings, nil)); err != nil {
+		return nil, err
+	}
+	if kind := model.Detector.Kind(); kind != conf.settings.Model.Type {
+		return nil, fmt.Errorf("saved model is %v but config wants %v", kind, conf.settings.Model.Type)
+	}
+	return model, nil
 }
 
 func main() {
 
-	// How many steps to run for
-	var nSteps = 30
-
-	// How often to retrain models
-	var trainEvery = 15
-
-	// define config for each chart we want and anomaly score for
-	var host = "london.my-netdata.io"
-	var trainAfter = "-100"
-	var trainBefore = "0"
-	var lags = 1
-	var diffs = 0
-	var smoothing = 2
-	config := map[string]map[string]interface{}{
-		"1": {"host": host, "chart": "system.net", "trainAfter": trainAfter, "trainBefore": trainBefore, "lags": lags, "diffs": diffs, "smoothing": smoothing},
-		"2": {"host": host, "chart": "system.ram", "trainAfter": trainAfter, "trainBefore": trainBefore, "lags": lags, "diffs": diffs, "smoothing": smoothing},
+	// Load config, everything about what to model and how comes from here
+	configPath := flag.String("config", "./config/example.json", "path to config file")
+	flag.Parse()
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// One conf per chart, kept in a slice so results can be matched back up by index
+	var confs []chartConf
+
+	// Create a pool shared by training and prediction fetches, or replay
+	// saved data if the config says to
+	pool := netdata.NewPool(cfg.Pool)
+	defer pool.Close()
+	var source netdata.Source = pool
+	var replay *netdata.Replay
+	if cfg.Replay != nil {
+		replay, err = cfg.Replay.Source()
+		if err != nil {
+			log.Fatal(err)
+		}
+		source = replay
+		fmt.Printf("Replaying %v from %v at %vx\n", cfg.Replay.Dir, replay.Start, cfg.Replay.Speed)
+	}
+
+	// Training windows come from the config, predictions use the last 20
+	// points or however many the chart's features need if that is more.
+	// Charts trained on averaged points are scored on points of the same
+	// size, as a model fit to averages finds raw rows too noisy.
+	trainWindow := func(conf chartConf) netdata.DataRequest {
+		return netdata.DataRequest{After: conf.settings.TrainAfter, Before: conf.settings.TrainBefore, Points: conf.settings.TrainPoints}
+	}
+	predWindow := func(conf chartConf) netdata.DataRequest {
+		rows := int64(conf.settings.Pipeline().MinRows())
+		if rows < 20 {
+			rows = 20
+		}
+		step := conf.settings.TrainStep()
+		if step == 1 {
+			return netdata.DataRequest{After: -rows}
+		}
+		return netdata.DataRequest{After: -rows*step + 1, Points: int(rows)}
 	}
 
 	// Create map to store trained models in
-	trainedModels := make(map[string]trees.IsolationForest, len(config))
+	trainedModels := make(map[string]*anomaly.Model)
+	unsaveable := make(map[string]bool)
 
 	// Run for nSteps
-	for i := 0; i <= nSteps; i++ {
+	for i := 0; i <= cfg.NSteps; i++ {
 
 		// Train models
-		if i%trainEvery == 0 {
-
-			// Get training data
-			trainDataChannel := make(chan map[string]base.FixedDataGrid, len(config))
+		if i%cfg.TrainEvery == 0 {
+
+			// Look for charts again so any new ones get picked up, keeping the
+			// ones we had for any host where discovery fails
+			trainCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.TrainTimeout))
+			confs = discoverCharts(trainCtx, cfg, replay, confs)
+
+			// Pick up any saved models for charts we have no model for yet,
+			// these don't need training this time round
+			var toTrain []chartConf
+			for _, conf := range confs {
+				key := conf.key(cfg)
+				if _, ok := trainedModels[key]; !ok && cfg.ModelDir != "" {
+					model, err := loadModel(cfg.ModelDir, conf, key)
+					if err == nil {
+						fmt.Printf("\nLoaded %v model trained on %v to %v\n", key, model.Features.TrainStart, model.Features.TrainEnd)
+						trainedModels[key] = model
+						continue
+					}
+					if !os.IsNotExist(err) {
+						log.Printf("Not using saved model for %v: %v\n", key, err)
+					}
+				}
+				toTrain = append(toTrain, conf)
+			}
 
 			// Get training data
-			for _, conf := range config {
-				wg.Add(1)
-				go getInstances(
-					conf["host"].(string),
-					conf["chart"].(string),
-					conf["trainAfter"].(string),
-					conf["trainBefore"].(string),
-					conf["lags"].(int),
-					conf["diffs"].(int),
-					conf["smoothing"].(int),
-					trainDataChannel,
-				)
-			}
-			wg.Wait()
-			close(trainDataChannel)
-
-			// Train each model and save it to trainedModels
-			for trainInstancesMap := range trainDataChannel {
-				for trainInstancesKey, trainInstancesData := range trainInstancesMap {
-					fmt.Printf("\nTraining %v model at: %v (step %v)\n", trainInstancesKey, time.Now().Unix(), i)
-					trainedModels[trainInstancesKey] = fitModel(trainInstancesData, 10, 10, 100)
+			trainData := getInstances(trainCtx, cfg, source, toTrain, trainWindow)
+			cancel()
+
+			// Train each model and save it to trainedModels, and to disk if we have somewhere to put it
+			for _, res := range trainData {
+				if res.err != nil {
+					log.Printf("Could not get training data for %v: %v\n", res.key, res.err)
+					continue
+				}
+				fmt.Printf("\nTraining %v model at: %v (step %v)\n", res.key, time.Now().Unix(), i)
+				detector, err := res.conf.settings.Model.Detector()
+				if err == nil {
+					err = detector.Fit(res.x)
+				}
+				if err != nil {
+					log.Printf("Could not train %v model: %v\n", res.key, err)
+					continue
+				}
+				model := &anomaly.Model{
+					Key:      res.key,
+					Features: featureConfig(res.conf.settings, res.x),
+					Detector: detector,
+				}
+				trainedModels[res.key] = model
+				if cfg.ModelDir != "" && !unsaveable[res.key] {
+					err := anomaly.SaveModel(anomaly.ModelPath(cfg.ModelDir, res.key), model)
+					if errors.Is(err, anomaly.ErrNotSaveable) {
+						// Only say so once
+						unsaveable[res.key] = true
+					}
+					if err != nil {
+						log.Printf("Could not save %v model: %v\n", res.key, err)
+					}
 				}
 			}
 
 		}
 
 		// Get prediction data
-		predDataChannel := make(chan map[string]base.FixedDataGrid, len(config))
-		for _, conf := range config {
-			wg.Add(1)
-			go getInstances(
-				conf["host"].(string),
-				conf["chart"].(string),
-				//string(-1*conf["lags"].(int)+conf["diffs"].(int)),
-				"-20",
-				"0",
-				conf["lags"].(int),
-				conf["diffs"].(int),
-				conf["smoothing"].(int),
-				predDataChannel,
-			)
-		}
-		wg.Wait()
-		close(predDataChannel)
-
-		// Make predictions
-		preds := make(map[string]float64)
-		for predInstancesMap := range predDataChannel {
-			for predInstancesKey, predInstancesData := range predInstancesMap {
-				//fmt.Println(predInstancesKey)
-				//fmt.Println(predInstancesData)
-				model := trainedModels[predInstancesKey]
-				recentPreds := model.Predict(predInstancesData)
-				//fmt.Println(recentPreds)
-				preds[predInstancesKey] = recentPreds[len(recentPreds)-1-diffs]
+		stepCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.StepTimeout))
+		predData := getInstances(stepCtx, cfg, source, confs, predWindow)
+		cancel()
+
+		// Make predictions with whatever came back in time
+		preds := make(map[string]anomaly.Series)
+		var timedOut, untrained []string
+		for _, res := range predData {
+			if res.err != nil {
+				if netdata.IsTimeout(res.err) {
+					timedOut = append(timedOut, res.key)
+				} else {
+					log.Println(res.err)
+				}
+				continue
+			}
+			// Skip charts we have never managed to train a model for
+			model, ok := trainedModels[res.key]
+			if !ok {
+				untrained = append(untrained, res.key)
+				continue
 			}
+			// A chart whose dimensions have changed needs a new model
+			if err := model.Features.Check(featureConfig(res.conf.settings, res.x)); err != nil {
+				log.Printf("Dropping %v model: %v\n", res.key, err)
+				delete(trainedModels, res.key)
+				continue
+			}
+			recentPreds, err := model.Detector.Score(res.x)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			//fmt.Println(recentPreds)
+			series, err := anomaly.NewSeries(res.key, res.x.Times, recentPreds)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			preds[res.key] = series
 		}
 
 		// Print scores at each step
 		fmt.Printf("\nAnomaly scores (step %v) as at: %v\n", i, time.Now().Unix())
-		fmt.Println(preds)
+		keys := make([]string, 0, len(preds))
+		for key := range preds {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Println(preds[key])
+		}
+		if len(timedOut) > 0 {
+			fmt.Printf("Timed out (step %v): %v\n", i, timedOut)
+		}
+		if len(untrained) > 0 {
+			fmt.Printf("No trained model (step %v): %v\n", i, untrained)
+		}
 
-		time.Sleep(500 * time.Millisecond)
+		time.Sleep(time.Duration(cfg.StepInterval))
 
 	}
 
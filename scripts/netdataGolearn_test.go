@@ -0,0 +1,106 @@
+// Each script is its own program, so test this one with its script:
+//
+//	go test scripts/netdataGolearn.go scripts/netdataGolearn_test.go
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andrewm4894/learn-go/config"
+	"github.com/andrewm4894/learn-go/fakenetdata"
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+var now = time.Unix(1600000000, 0)
+
+// A config for a fake netdata with its clock stopped, every system chart
+// but uptime is discovered and cpu and load are also modelled as a group
+func fakeConfig(t *testing.T) (*config.Config, *httptest.Server) {
+	t.Helper()
+	s := fakenetdata.New(1)
+	s.Now = func() time.Time { return now }
+	ts := httptest.NewServer(s)
+	t.Cleanup(ts.Close)
+
+	cfg, err := config.Parse([]byte(fmt.Sprintf(`{"hosts": [{
+		"host": %q,
+		"filter": {"types": {"include": ["system"]}, "ids": {"exclude": ["system.uptime"]}},
+		"lags": 2,
+		"groups": [{"name": "cpu-load", "charts": ["system.cpu", "system.load"]}]
+	}]}`, ts.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg, ts
+}
+
+func TestDiscoverCharts(t *testing.T) {
+	cfg, ts := fakeConfig(t)
+	confs := discoverCharts(context.Background(), cfg, nil, nil)
+	var got []string
+	for _, conf := range confs {
+		got = append(got, conf.chart)
+	}
+	if want := "system.cpu system.io system.load system.net cpu-load"; strings.Join(got, " ") != want {
+		t.Errorf("charts %v, want %v", got, want)
+	}
+
+	// A host that can't be reached keeps what it had
+	ts.Close()
+	if again := discoverCharts(context.Background(), cfg, nil, confs); len(again) != len(confs) {
+		t.Errorf("unreachable host gave %d charts, want the %d it had", len(again), len(confs))
+	}
+}
+
+func TestGetInstances(t *testing.T) {
+	cfg, _ := fakeConfig(t)
+	confs := discoverCharts(context.Background(), cfg, nil, nil)
+	confs = append(confs, chartConf{host: cfg.Hosts[0], chart: "nope", settings: cfg.Hosts[0].Settings})
+	pool := netdata.NewPool(cfg.Pool)
+	defer pool.Close()
+
+	window := func(conf chartConf) netdata.DataRequest { return netdata.DataRequest{After: -99} }
+	results := getInstances(context.Background(), cfg, pool, confs, window)
+	if len(results) != len(confs) {
+		t.Fatalf("got %d results for %d charts", len(results), len(confs))
+	}
+
+	dims := map[string]int{"system.cpu": 3, "system.io": 2, "system.load": 3, "system.net": 2, "cpu-load": 6}
+	for i, res := range results {
+		if res.conf.chart == "nope" {
+			if res.err == nil {
+				t.Error("unknown chart: got no error")
+			}
+			continue
+		}
+		if res.err != nil {
+			t.Errorf("%v: %v", res.key, res.err)
+			continue
+		}
+		if res.key != cfg.Client(cfg.Hosts[0]).Host()+"|"+confs[i].chart {
+			t.Errorf("result %d has key %v for %v", i, res.key, confs[i].chart)
+		}
+
+		// 100 rows a second apart, less those used up making features, with
+		// each dimension and its 2 lags
+		rows, cols := res.x.Dims()
+		if want := 100 - res.conf.settings.Pipeline().MinRows() + 1; rows != want {
+			t.Errorf("%v: %d rows, want %d", res.key, rows, want)
+		}
+		if want := 3 * dims[res.conf.chart]; cols != want || len(res.x.Names) != want {
+			t.Errorf("%v: %d columns named %v, want %d", res.key, cols, res.x.Names, want)
+		}
+		if newest := res.x.Times[len(res.x.Times)-1]; newest != now.Unix() {
+			t.Errorf("%v: newest row at %v, want %v", res.key, newest, now.Unix())
+		}
+		if res.conf.group != nil && !strings.HasPrefix(res.x.Names[0], "system.cpu|") {
+			t.Errorf("%v: group columns %v, want them named chart|dimension", res.key, res.x.Names)
+		}
+	}
+}
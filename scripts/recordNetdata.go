@@ -0,0 +1,116 @@
+// Record charts from a netdata host into an archive that can be replayed
+// later, by setting it as the replay dir in a config
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/andrewm4894/learn-go/netdata"
+)
+
+func main() {
+
+	host := flag.String("host", "london.my-netdata.io", "netdata host to record")
+	chartList := flag.String("charts", "", "comma separated chart ids to record, all of the host's if empty")
+	out := flag.String("out", "./data/archive.jsonl.gz", "archive to append to")
+	every := flag.Duration("every", 10*time.Second, "how often to poll")
+	history := flag.Duration("history", 10*time.Minute, "how far back to get on the first poll")
+	duration := flag.Duration("for", 0, "how long to record for, until interrupted if 0")
+	flag.Parse()
+
+	// Stop on ctrl-c, or after -for
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		cancel()
+	}()
+
+	client := netdata.NewClient(*host)
+	client.Timeout = 5 * time.Second
+	retry := netdata.DefaultRetryPolicy
+	client.Retry = &retry
+
+	// Charts to record
+	var charts []string
+	if *chartList != "" {
+		charts = strings.Split(*chartList, ",")
+	} else {
+		discovered, err := client.DiscoverCharts(ctx, netdata.ChartFilter{})
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, chart := range discovered {
+			charts = append(charts, chart.ID)
+		}
+	}
+
+	recorder, err := netdata.NewRecorder(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pool := netdata.NewPool(netdata.PoolOptions{Workers: 4, PerHost: 4})
+	defer pool.Close()
+
+	// Close the recorder before any log.Fatal, which skips deferred calls,
+	// so what was recorded is flushed
+	err = record(ctx, pool, client, charts, recorder, *every, *history)
+	if closeErr := recorder.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Poll charts every so often until ctx is done, recording each response
+func record(ctx context.Context, pool *netdata.Pool, client *netdata.Client, charts []string, recorder *netdata.Recorder, every, history time.Duration) error {
+
+	// Each poll asks for a little more than the time since the last one so
+	// nothing is missed, the overlap is merged away on replay
+	after := -int64(history.Seconds())
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		jobs := make([]netdata.Job, len(charts))
+		for i, chart := range charts {
+			jobs[i] = netdata.Job{Client: client, Request: netdata.DataRequest{Chart: chart, After: after}}
+		}
+		fetchedAt := time.Now()
+		failed := 0
+		for _, res := range pool.Fetch(ctx, jobs) {
+			if res.Err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				log.Printf("%v: %v\n", res.Job.Key(), res.Err)
+				failed++
+			}
+			if err := recorder.Record(res, fetchedAt); err != nil {
+				return err
+			}
+		}
+		log.Printf("Recorded %v charts, %v failed\n", len(charts), failed)
+		after = -int64(every.Seconds()) - 2
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}